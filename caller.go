@@ -0,0 +1,84 @@
+package zipkinsql
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// defaultCallerMaxDepth is the number of stack frames inspected when looking
+// for the caller if no CallerMaxDepth is set.
+const defaultCallerMaxDepth = 32
+
+// callerSkipPackages are the packages never reported as the caller of a SQL
+// call, subpackages included.
+var callerSkipPackages = []string{
+	"runtime",
+	"database/sql",
+	"github.com/jmoiron/sqlx",
+	"github.com/openzipkin-contrib/zipkin-go-sql",
+	"gorm.io/gorm",
+}
+
+// setSpanCaller tags the span with the function and location of the first
+// stack frame not belonging to database/sql, sqlx, GORM, zipkinsql or the
+// packages listed in CallerSkipPackages.
+func setSpanCaller(span Span, options TraceOptions) {
+	if !options.TagCaller {
+		return
+	}
+
+	frame, ok := findCaller(options)
+	if !ok {
+		return
+	}
+
+	span.Tag("sql.caller.func", frame.Function)
+	span.Tag("sql.caller.file", frame.File+":"+strconv.Itoa(frame.Line))
+}
+
+func findCaller(options TraceOptions) (runtime.Frame, bool) {
+	depth := options.CallerMaxDepth
+	if depth <= 0 {
+		depth = defaultCallerMaxDepth
+	}
+
+	pcs := make([]uintptr, depth)
+	// skip runtime.Callers, findCaller and setSpanCaller
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" && !isSkippedPackage(funcPackage(frame.Function), options.CallerSkipPackages) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+func isSkippedPackage(pkg string, extra []string) bool {
+	for _, skip := range callerSkipPackages {
+		if pkg == skip || strings.HasPrefix(pkg, skip+"/") {
+			return true
+		}
+	}
+	for _, skip := range extra {
+		if pkg == skip || strings.HasPrefix(pkg, skip+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// funcPackage returns the import path of the package a function, as reported
+// by runtime.Frame, belongs to, e.g. "github.com/jmoiron/sqlx" for
+// "github.com/jmoiron/sqlx.(*DB).QueryRowx".
+func funcPackage(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[lastSlash+1:], "."); dot >= 0 {
+		return function[:lastSlash+1+dot]
+	}
+	return function
+}
@@ -0,0 +1,85 @@
+package zipkinsql_test
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+// queryOne runs a query from outside zipkinsql and returns the location it
+// was issued from.
+func queryOne(db *sql.DB) (string, error) {
+	_, file, line, _ := runtime.Caller(0)
+	rows, err := db.QueryContext(context.Background(), "SELECT 1")
+	if err != nil {
+		return "", err
+	}
+	return file + ":" + strconv.Itoa(line+1), rows.Close()
+}
+
+func TestCallerTag(t *testing.T) {
+	testCases := []struct {
+		opts       []zipkinsql.TraceOption
+		callerFunc string
+	}{
+		{[]zipkinsql.TraceOption{zipkinsql.WithAllowRootSpan(true)}, ""},
+		{[]zipkinsql.TraceOption{zipkinsql.WithAllowRootSpan(true), zipkinsql.WithTagCaller(true)},
+			"github.com/openzipkin-contrib/zipkin-go-sql_test.queryOne"},
+		{[]zipkinsql.TraceOption{zipkinsql.WithAllowRootSpan(true), zipkinsql.WithTagCaller(true),
+			zipkinsql.WithCallerSkipPackages("github.com/openzipkin-contrib/zipkin-go-sql_test")}, "testing.tRunner"},
+	}
+
+	for _, c := range testCases {
+		reporter := zipkinreporter.NewReporter()
+		tracer, _ := zipkin.NewTracer(reporter)
+
+		driverName, err := zipkinsql.Register("sqlite3", tracer, c.opts...)
+		if err != nil {
+			t.Fatalf("unable to register driver: %s", err.Error())
+		}
+		db, err := sql.Open(driverName, "file:test.db?cache=shared&mode=memory")
+		if err != nil {
+			t.Fatalf("unable to open db: %s", err.Error())
+		}
+
+		location, err := queryOne(db)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		spans := reporter.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+		}
+
+		file, ok := spans[0].Tags["sql.caller.file"]
+		if want, have := c.callerFunc != "", ok; want != have {
+			t.Fatalf("unexpected presence of the caller tag, want: %t, have: %t", want, have)
+		}
+		if want, have := c.callerFunc, spans[0].Tags["sql.caller.func"]; want != have {
+			t.Errorf("unexpected caller function, want: %s, have: %s", want, have)
+		}
+		switch c.callerFunc {
+		case "":
+		case "testing.tRunner":
+			if !strings.Contains(file, "testing.go:") {
+				t.Errorf("unexpected caller file: %s", file)
+			}
+		default:
+			if want, have := location, file; want != have {
+				t.Errorf("unexpected caller file, want: %s, have: %s", want, have)
+			}
+		}
+
+		db.Close()
+		reporter.Close()
+	}
+}
@@ -0,0 +1,41 @@
+package zipkinsql
+
+import "testing"
+
+func TestFuncPackage(t *testing.T) {
+	testCases := map[string]string{
+		"main.main":                          "main",
+		"database/sql.(*DB).QueryContext":    "database/sql",
+		"github.com/jmoiron/sqlx.(*DB).Getx": "github.com/jmoiron/sqlx",
+		"github.com/openzipkin-contrib/zipkin-go-sql.zConn.ExecContext.func1": "github.com/openzipkin-contrib/zipkin-go-sql",
+		"example.com/app/repo.(*Users).Find":                                  "example.com/app/repo",
+		"example.com/app.v2/repo.Find":                                        "example.com/app.v2/repo",
+		"gorm.io/gorm.(*DB).Find":                                             "gorm.io/gorm",
+	}
+	for function, want := range testCases {
+		if have := funcPackage(function); want != have {
+			t.Errorf("unexpected package for %s, want: %s, have: %s", function, want, have)
+		}
+	}
+}
+
+func TestIsSkippedPackage(t *testing.T) {
+	testCases := map[string]bool{
+		"database/sql":                                     true,
+		"github.com/jmoiron/sqlx":                          true,
+		"gorm.io/gorm":                                     true,
+		"gorm.io/gorm/callbacks":                           true,
+		"gorm.io/driver/postgres":                          false,
+		"github.com/openzipkin-contrib/zipkin-go-sql":      true,
+		"github.com/openzipkin-contrib/zipkin-go-sql_test": false,
+		"example.com/app/repo":                             false,
+	}
+	for pkg, want := range testCases {
+		if have := isSkippedPackage(pkg, nil); want != have {
+			t.Errorf("unexpected skip of %s, want: %t, have: %t", pkg, want, have)
+		}
+	}
+	if !isSkippedPackage("example.com/app/repo/users", []string{"example.com/app/repo"}) {
+		t.Error("expected the subpackage of an extra package to be skipped")
+	}
+}
@@ -0,0 +1,176 @@
+package zipkinsql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	optionsCtxKey    struct{}
+	tagsCtxKey       struct{}
+	txTagsCtxKey     struct{}
+	namedQueryCtxKey struct{}
+	spanNameCtxKey   struct{}
+	disabledCtxKey   struct{}
+	dbCallCtxKey     struct{}
+	dbTxCtxKey       struct{}
+)
+
+// WithContextOptions returns a copy of ctx carrying TraceOptions which override
+// the ones the driver was wrapped with for the calls made using that context.
+// This allows, for instance, to enable TagQueryParams for a single request
+// without registering a second driver. Options are applied on top of the
+// driver ones, including overrides previously attached to ctx, so WithOptions
+// and WithAllTraceOptions replace every tracing flag.
+func WithContextOptions(ctx context.Context, options ...TraceOption) context.Context {
+	prev, _ := ctx.Value(optionsCtxKey{}).([]TraceOption)
+	opts := make([]TraceOption, 0, len(prev)+len(options))
+	opts = append(append(opts, prev...), options...)
+	return context.WithValue(ctx, optionsCtxKey{}, opts)
+}
+
+// WithContextTags returns a copy of ctx carrying tags to be set, along with
+// the DefaultTags, to the spans of the calls made using that context. Tags
+// already attached to ctx are kept unless overridden.
+func WithContextTags(ctx context.Context, tags map[string]string) context.Context {
+	prev, _ := ctx.Value(tagsCtxKey{}).(map[string]string)
+	return context.WithValue(ctx, tagsCtxKey{}, mergeTags(prev, tags))
+}
+
+// WithTxContextTags returns a copy of ctx carrying tags to be set to the spans
+// of a transaction started with that context, including the spans of every
+// statement run within the transaction regardless of the context they use.
+func WithTxContextTags(ctx context.Context, tags map[string]string) context.Context {
+	prev, _ := ctx.Value(txTagsCtxKey{}).(map[string]string)
+	return context.WithValue(ctx, txTagsCtxKey{}, mergeTags(prev, tags))
+}
+
+// WithContextNamedQuery returns a copy of ctx carrying the named query a
+// library like sqlx rewrote into the query sent to the driver. It is recorded
+// in the sql.query.named tag of the spans of the calls made using that
+// context when TagQuery is enabled.
+func WithContextNamedQuery(ctx context.Context, query string) context.Context {
+	return context.WithValue(ctx, namedQueryCtxKey{}, query)
+}
+
+// WithContextSpanName returns a copy of ctx carrying the name given to the
+// exec and query spans of the calls made using that context instead of
+// sql/exec and sql/query. This lets ORMs, e.g. the zipkinsqlgorm package,
+// describe the operation without starting spans of their own.
+func WithContextSpanName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, spanNameCtxKey{}, name)
+}
+
+// WithContextTracingDisabled returns a copy of ctx disabling the spans of the
+// calls made using that context, even if it carries a parent span, e.g. for
+// health checks or bulk jobs. Metrics are still recorded and interceptors
+// still run.
+func WithContextTracingDisabled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disabledCtxKey{}, true)
+}
+
+func txTagsFromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(txTagsCtxKey{}).(map[string]string)
+	return tags
+}
+
+// optionsFromContext returns o with the overrides and tags attached to ctx
+// applied.
+func optionsFromContext(ctx context.Context, o TraceOptions) TraceOptions {
+	if opts, ok := ctx.Value(optionsCtxKey{}).([]TraceOption); ok {
+		for _, option := range opts {
+			option(&o)
+		}
+		if o.TagQueryParams && !o.TagQuery {
+			o.TagQueryParams = false
+		}
+	}
+
+	if tags, ok := ctx.Value(tagsCtxKey{}).(map[string]string); ok {
+		o.DefaultTags = mergeTags(o.DefaultTags, tags)
+	}
+
+	o.namedQuery, _ = ctx.Value(namedQueryCtxKey{}).(string)
+	o.spanName, _ = ctx.Value(spanNameCtxKey{}).(string)
+	o.tracingDisabled, _ = ctx.Value(disabledCtxKey{}).(bool)
+	return o
+}
+
+// traceOptions returns the options applying to a call made with ctx on the
+// connection, including the tags of the ongoing transaction. Being called
+// first by the calls made on the connection, it also ends the wait for a
+// connection measured by the DB span, if any.
+func (c *zConn) traceOptions(ctx context.Context) TraceOptions {
+	connAcquired(ctx)
+	o := c.options
+	if len(c.txTags) > 0 {
+		o.DefaultTags = mergeTags(o.DefaultTags, c.txTags)
+	}
+	return optionsFromContext(ctx, o)
+}
+
+// mergeTags returns a new map holding the tags of both maps, the ones in
+// overrides taking precedence.
+func mergeTags(tags, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(tags)+len(overrides))
+	for key, value := range tags {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+// dbCall is carried by the context of the calls waiting for a connection of
+// the pool.
+type dbCall struct {
+	start    time.Time
+	once     sync.Once
+	acquired time.Duration
+	ok       bool
+}
+
+// connAcquired records that the call made with ctx, if waiting for a
+// connection of the pool, got one.
+func connAcquired(ctx context.Context) {
+	if call, ok := ctx.Value(dbCallCtxKey{}).(*dbCall); ok {
+		call.once.Do(func() {
+			call.acquired, call.ok = time.Since(call.start), true
+		})
+	}
+}
+
+// wait returns the time spent waiting for a connection, if one was acquired.
+func (c *dbCall) wait() (time.Duration, bool) {
+	return c.acquired, c.ok
+}
+
+// dbTxEnd is carried by the context of the transactions started through the
+// DB facade, the context of its commit or rollback span being set before the
+// driver transaction ends.
+type dbTxEnd struct {
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+func (e *dbTxEnd) set(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ctx = ctx
+}
+
+// txEndContext returns the context the commit and rollback spans of the
+// transaction started with ctx start from: the one of the DB facade commit or
+// rollback span, if any, or ctx.
+func txEndContext(ctx context.Context) context.Context {
+	if e, ok := ctx.Value(dbTxCtxKey{}).(*dbTxEnd); ok {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.ctx != nil {
+			return e.ctx
+		}
+	}
+	return ctx
+}
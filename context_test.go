@@ -0,0 +1,230 @@
+package zipkinsql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContextOptions(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(false))
+	defer db.Close()
+	defer recorder.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1 WHERE 1 = ?", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+
+	if want, have := 0, len(recorder.Flush()); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+
+	ctx := WithContextOptions(context.Background(), WithAllowRootSpan(true), WithTagQuery(true))
+	ctx = WithContextOptions(ctx, WithTagQueryParams(true))
+
+	rows, err = db.QueryContext(ctx, "SELECT 1 WHERE 1 = ?", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+
+	spans := recorder.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := "SELECT 1 WHERE 1 = ?", spans[0].Tags["sql.query"]; want != have {
+		t.Errorf("unexpected query tag, want: %q, have: %q", want, have)
+	}
+	if want, have := "1", spans[0].Tags["sql.arg.1"]; want != have {
+		t.Errorf("unexpected param tag, want: %q, have: %q", want, have)
+	}
+}
+
+func TestContextOptionsTx(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(false))
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx := WithContextOptions(context.Background(), WithAllowRootSpan(true))
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans := recorder.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := "sql/begin_transaction", spans[0].Name; want != have {
+		t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+	}
+	if want, have := "sql/commit", spans[1].Name; want != have {
+		t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+	}
+}
+
+func TestContextTags(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(true), WithDefaultTags(map[string]string{
+		"service": "users",
+		"tenant":  "none",
+	}))
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx := WithContextTags(context.Background(), map[string]string{"tenant": "acme"})
+	ctx = WithContextTags(ctx, map[string]string{"method": "UserRepository.Find"})
+
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+
+	spans := recorder.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	for key, want := range map[string]string{
+		"service": "users",
+		"tenant":  "acme",
+		"method":  "UserRepository.Find",
+	} {
+		if have := spans[0].Tags[key]; want != have {
+			t.Errorf("unexpected %s tag, want: %q, have: %q", key, want, have)
+		}
+	}
+}
+
+func TestTxContextTags(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx := WithTxContextTags(context.Background(), map[string]string{"feature": "checkout"})
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err = tx.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, err = db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans := recorder.Flush()
+	if want, have := 4, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	for i, name := range []string{"sql/begin_transaction", "sql/exec", "sql/commit"} {
+		if want, have := name, spans[i].Name; want != have {
+			t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+		}
+		if want, have := "checkout", spans[i].Tags["feature"]; want != have {
+			t.Errorf("unexpected feature tag on %s, want: %q, have: %q", name, want, have)
+		}
+	}
+	if feature, ok := spans[3].Tags["feature"]; ok {
+		t.Errorf("unexpected feature tag after the transaction ended: %q", feature)
+	}
+}
+
+func TestContextSpanName(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx := WithContextSpanName(context.Background(), "users.Find")
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+
+	spans := recorder.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := "users.Find", spans[0].Name; want != have {
+		t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+	}
+}
+
+func TestContextTracingDisabled(t *testing.T) {
+	metrics := &metricsRecorder{}
+	db, tracer, recorder := createDB(t, WithAllowRootSpan(true), WithMetrics(metrics))
+	defer db.Close()
+	defer recorder.Close()
+
+	root, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	ctx = WithContextTracingDisabled(ctx)
+
+	if _, err := db.ExecContext(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	root.Finish()
+
+	spans := recorder.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := "root", spans[0].Name; want != have {
+		t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+	}
+	if len(metrics.latencies) == 0 {
+		t.Error("expected the metrics to be recorded")
+	}
+}
+
+func TestContextSpanNameFormatter(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx := WithContextOptions(context.Background(), WithSpanNameFormatter(func(name string) string {
+		return "checkout " + name
+	}))
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	stmt, err := tx.PrepareContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	stmt.Close()
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans := recorder.Flush()
+	if want, have := 4, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	for i, name := range []string{"sql/begin_transaction", "sql/prepare", "sql/exec", "sql/commit"} {
+		if want, have := "checkout "+name, spans[i].Name; want != have {
+			t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+		}
+	}
+}
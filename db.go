@@ -0,0 +1,546 @@
+//go:build go1.15
+// +build go1.15
+
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+)
+
+// DB wraps a *sql.DB with the same methods, each call being traced by a
+// logical span, e.g. db/query, enclosing the spans of the zipkinsql wrapped
+// driver. Unlike the driver spans, it covers the whole time spent in
+// database/sql: the wait for a connection of the pool, tagged sql.pool.wait,
+// and, for QueryRow and Query, the scan of the results, scan errors included.
+// sql.ErrNoRows is tagged sql.no_rows rather than recorded as an error.
+// The logical spans follow the AllowRootSpan, TagQuery, TagCaller,
+// SpanNameFormatter, DefaultTags and RemoteEndpoint options as well as the per
+// call options set with WithContextOptions and WithContextTracingDisabled.
+type DB struct {
+	dbTracing
+	db *sql.DB
+}
+
+// NewDB wraps db, usually opened with a zipkinsql wrapped driver, Open or
+// OpenDB, which spans then become children of the logical ones.
+// It panics if neither t nor the Tracer option is set.
+func NewDB(db *sql.DB, t *zipkin.Tracer, options ...TraceOption) *DB {
+	o := TraceOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+	return &DB{dbTracing: dbTracing{tracer: newTracer(t, o), options: o}, db: db}
+}
+
+// Unwrap returns the wrapped *sql.DB.
+func (db *DB) Unwrap() *sql.DB {
+	return db.db
+}
+
+// Begin starts a transaction.
+func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// BeginTx starts a transaction.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *Tx, err error) {
+	span, spanCtx := db.start(ctx, "db/begin_transaction", "", true)
+	defer func() { span.finish(err) }()
+
+	end := &dbTxEnd{}
+	t, err := db.db.BeginTx(context.WithValue(spanCtx, dbTxCtxKey{}, end), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{dbTracing: db.dbTracing, tx: t, ctx: ctx, end: end}, nil
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// Conn returns a single connection of the pool. The span of the call covers
+// the wait for the connection.
+func (db *DB) Conn(ctx context.Context) (conn *Conn, err error) {
+	startTime := time.Now()
+	span, ctx := db.start(ctx, "db/conn", "", false)
+	defer func() {
+		if span != nil && err == nil {
+			span.span.Tag("sql.pool.wait", time.Since(startTime).String())
+		}
+		span.finish(err)
+	}()
+
+	c, err := db.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{dbTracing: db.dbTracing, conn: c}, nil
+}
+
+// Driver returns the driver of the database.
+func (db *DB) Driver() driver.Driver {
+	return db.db.Driver()
+}
+
+// Exec executes a query without returning any rows.
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a query without returning any rows.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	span, ctx := db.start(ctx, "db/exec", query, true)
+	defer func() { span.finish(err) }()
+
+	return db.db.ExecContext(ctx, query, args...)
+}
+
+// Ping verifies a connection to the database is still alive.
+func (db *DB) Ping() error {
+	return db.PingContext(context.Background())
+}
+
+// PingContext verifies a connection to the database is still alive.
+func (db *DB) PingContext(ctx context.Context) (err error) {
+	span, ctx := db.start(ctx, "db/ping", "", true)
+	defer func() { span.finish(err) }()
+
+	return db.db.PingContext(ctx)
+}
+
+// Prepare creates a prepared statement.
+func (db *DB) Prepare(query string) (*Stmt, error) {
+	return db.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext creates a prepared statement.
+func (db *DB) PrepareContext(ctx context.Context, query string) (stmt *Stmt, err error) {
+	span, ctx := db.start(ctx, "db/prepare", query, true)
+	defer func() { span.finish(err) }()
+
+	s, err := db.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{dbTracing: db.dbTracing, stmt: s, query: query, pool: true}, nil
+}
+
+// Query executes a query returning rows.
+func (db *DB) Query(query string, args ...interface{}) (*Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query returning rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	span, ctx := db.start(ctx, "db/query", query, true)
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	return newRows(rows, span, err)
+}
+
+// QueryRow executes a query returning at most one row.
+func (db *DB) QueryRow(query string, args ...interface{}) *Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext executes a query returning at most one row.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	span, ctx := db.start(ctx, "db/query_row", query, true)
+	return &Row{row: db.db.QueryRowContext(ctx, query, args...), span: span}
+}
+
+// SetConnMaxIdleTime sets the maximum amount of time a connection may be idle.
+func (db *DB) SetConnMaxIdleTime(d time.Duration) {
+	db.db.SetConnMaxIdleTime(d)
+}
+
+// SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
+func (db *DB) SetConnMaxLifetime(d time.Duration) {
+	db.db.SetConnMaxLifetime(d)
+}
+
+// SetMaxIdleConns sets the maximum number of idle connections.
+func (db *DB) SetMaxIdleConns(n int) {
+	db.db.SetMaxIdleConns(n)
+}
+
+// SetMaxOpenConns sets the maximum number of open connections.
+func (db *DB) SetMaxOpenConns(n int) {
+	db.db.SetMaxOpenConns(n)
+}
+
+// Stats returns the statistics of the connection pool.
+func (db *DB) Stats() sql.DBStats {
+	return db.db.Stats()
+}
+
+// Conn wraps a *sql.Conn with the same methods, each call being traced by a
+// logical span. See DB.
+type Conn struct {
+	dbTracing
+	conn *sql.Conn
+}
+
+// Unwrap returns the wrapped *sql.Conn.
+func (c *Conn) Unwrap() *sql.Conn {
+	return c.conn
+}
+
+// BeginTx starts a transaction.
+func (c *Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *Tx, err error) {
+	span, spanCtx := c.start(ctx, "db/begin_transaction", "", false)
+	defer func() { span.finish(err) }()
+
+	end := &dbTxEnd{}
+	t, err := c.conn.BeginTx(context.WithValue(spanCtx, dbTxCtxKey{}, end), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{dbTracing: c.dbTracing, tx: t, ctx: ctx, end: end}, nil
+}
+
+// Close returns the connection to the pool.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// ExecContext executes a query without returning any rows.
+func (c *Conn) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	span, ctx := c.start(ctx, "db/exec", query, false)
+	defer func() { span.finish(err) }()
+
+	return c.conn.ExecContext(ctx, query, args...)
+}
+
+// PingContext verifies the connection is still alive.
+func (c *Conn) PingContext(ctx context.Context) (err error) {
+	span, ctx := c.start(ctx, "db/ping", "", false)
+	defer func() { span.finish(err) }()
+
+	return c.conn.PingContext(ctx)
+}
+
+// PrepareContext creates a prepared statement.
+func (c *Conn) PrepareContext(ctx context.Context, query string) (stmt *Stmt, err error) {
+	span, ctx := c.start(ctx, "db/prepare", query, false)
+	defer func() { span.finish(err) }()
+
+	s, err := c.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{dbTracing: c.dbTracing, stmt: s, query: query}, nil
+}
+
+// QueryContext executes a query returning rows.
+func (c *Conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	span, ctx := c.start(ctx, "db/query", query, false)
+	rows, err := c.conn.QueryContext(ctx, query, args...)
+	return newRows(rows, span, err)
+}
+
+// QueryRowContext executes a query returning at most one row.
+func (c *Conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	span, ctx := c.start(ctx, "db/query_row", query, false)
+	return &Row{row: c.conn.QueryRowContext(ctx, query, args...), span: span}
+}
+
+// Raw calls f with the driver connection, see UnwrapConn.
+func (c *Conn) Raw(f func(driverConn interface{}) error) error {
+	return c.conn.Raw(f)
+}
+
+// Tx wraps a *sql.Tx with the same methods, each call being traced by a
+// logical span. See DB.
+type Tx struct {
+	dbTracing
+	tx *sql.Tx
+	// ctx is the context the transaction was started with, which the commit
+	// and rollback spans start from.
+	ctx context.Context
+	// end passes the context of the commit or rollback span to the driver
+	// transaction, which spans become its children.
+	end *dbTxEnd
+}
+
+// Unwrap returns the wrapped *sql.Tx.
+func (t *Tx) Unwrap() *sql.Tx {
+	return t.tx
+}
+
+// Commit commits the transaction.
+func (t *Tx) Commit() (err error) {
+	span, ctx := t.start(t.ctx, "db/commit", "", false)
+	defer func() { span.finish(err) }()
+
+	t.end.set(ctx)
+
+	return t.tx.Commit()
+}
+
+// Exec executes a query without returning any rows.
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a query without returning any rows.
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	span, ctx := t.start(ctx, "db/exec", query, false)
+	defer func() { span.finish(err) }()
+
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+// Prepare creates a prepared statement for use within the transaction.
+func (t *Tx) Prepare(query string) (*Stmt, error) {
+	return t.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext creates a prepared statement for use within the transaction.
+func (t *Tx) PrepareContext(ctx context.Context, query string) (stmt *Stmt, err error) {
+	span, ctx := t.start(ctx, "db/prepare", query, false)
+	defer func() { span.finish(err) }()
+
+	s, err := t.tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{dbTracing: t.dbTracing, stmt: s, query: query}, nil
+}
+
+// Query executes a query returning rows.
+func (t *Tx) Query(query string, args ...interface{}) (*Rows, error) {
+	return t.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query returning rows.
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	span, ctx := t.start(ctx, "db/query", query, false)
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	return newRows(rows, span, err)
+}
+
+// QueryRow executes a query returning at most one row.
+func (t *Tx) QueryRow(query string, args ...interface{}) *Row {
+	return t.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext executes a query returning at most one row.
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	span, ctx := t.start(ctx, "db/query_row", query, false)
+	return &Row{row: t.tx.QueryRowContext(ctx, query, args...), span: span}
+}
+
+// Rollback aborts the transaction.
+func (t *Tx) Rollback() (err error) {
+	span, ctx := t.start(t.ctx, "db/rollback", "", false)
+	defer func() { span.finish(err) }()
+
+	t.end.set(ctx)
+
+	return t.tx.Rollback()
+}
+
+// Stmt returns a transaction specific prepared statement from stmt.
+func (t *Tx) Stmt(stmt *Stmt) *Stmt {
+	return t.StmtContext(context.Background(), stmt)
+}
+
+// StmtContext returns a transaction specific prepared statement from stmt.
+func (t *Tx) StmtContext(ctx context.Context, stmt *Stmt) *Stmt {
+	return &Stmt{dbTracing: t.dbTracing, stmt: t.tx.StmtContext(ctx, stmt.stmt), query: stmt.query}
+}
+
+// Stmt wraps a *sql.Stmt with the same methods, each call being traced by a
+// logical span. See DB.
+type Stmt struct {
+	dbTracing
+	stmt  *sql.Stmt
+	query string
+	// pool tells if the statement is executed on a connection of the pool,
+	// i.e. was prepared on a DB.
+	pool bool
+}
+
+// Unwrap returns the wrapped *sql.Stmt.
+func (s *Stmt) Unwrap() *sql.Stmt {
+	return s.stmt
+}
+
+// Close closes the statement.
+func (s *Stmt) Close() error {
+	return s.stmt.Close()
+}
+
+// Exec executes the statement without returning any rows.
+func (s *Stmt) Exec(args ...interface{}) (sql.Result, error) {
+	return s.ExecContext(context.Background(), args...)
+}
+
+// ExecContext executes the statement without returning any rows.
+func (s *Stmt) ExecContext(ctx context.Context, args ...interface{}) (res sql.Result, err error) {
+	span, ctx := s.start(ctx, "db/exec", s.query, s.pool)
+	defer func() { span.finish(err) }()
+
+	return s.stmt.ExecContext(ctx, args...)
+}
+
+// Query executes the statement returning rows.
+func (s *Stmt) Query(args ...interface{}) (*Rows, error) {
+	return s.QueryContext(context.Background(), args...)
+}
+
+// QueryContext executes the statement returning rows.
+func (s *Stmt) QueryContext(ctx context.Context, args ...interface{}) (*Rows, error) {
+	span, ctx := s.start(ctx, "db/query", s.query, s.pool)
+	rows, err := s.stmt.QueryContext(ctx, args...)
+	return newRows(rows, span, err)
+}
+
+// QueryRow executes the statement returning at most one row.
+func (s *Stmt) QueryRow(args ...interface{}) *Row {
+	return s.QueryRowContext(context.Background(), args...)
+}
+
+// QueryRowContext executes the statement returning at most one row.
+func (s *Stmt) QueryRowContext(ctx context.Context, args ...interface{}) *Row {
+	span, ctx := s.start(ctx, "db/query_row", s.query, s.pool)
+	return &Row{row: s.stmt.QueryRowContext(ctx, args...), span: span}
+}
+
+// Rows wraps *sql.Rows, the logical span of the query ending once Next
+// returns false or the rows are closed. It records the first scan error.
+type Rows struct {
+	*sql.Rows
+	span    *dbSpan
+	scanErr error
+}
+
+func newRows(rows *sql.Rows, span *dbSpan, err error) (*Rows, error) {
+	if err != nil {
+		span.finish(err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, span: span}, nil
+}
+
+// Next prepares the next row for reading with Scan.
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.finish(r.Rows.Err())
+	return false
+}
+
+// Scan copies the columns of the current row into dest.
+func (r *Rows) Scan(dest ...interface{}) error {
+	err := r.Rows.Scan(dest...)
+	if err != nil && r.scanErr == nil {
+		r.scanErr = err
+	}
+	return err
+}
+
+// Close closes the rows.
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.finish(err)
+	return err
+}
+
+func (r *Rows) finish(err error) {
+	if err == nil {
+		err = r.scanErr
+	}
+	r.span.finish(err)
+	r.span = nil
+}
+
+// Row wraps *sql.Row, the logical span of the query ending once scanned.
+type Row struct {
+	row  *sql.Row
+	span *dbSpan
+}
+
+// Scan copies the columns of the row into dest, returning sql.ErrNoRows if
+// there is none.
+func (r *Row) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	r.span.finish(err)
+	r.span = nil
+	return err
+}
+
+// Err returns the error of the query, if any, without scanning.
+func (r *Row) Err() error {
+	return r.row.Err()
+}
+
+// dbTracing starts the logical spans of DB, Conn, Tx and Stmt.
+type dbTracing struct {
+	tracer  Tracer
+	options TraceOptions
+}
+
+// start starts the logical span of a call made with ctx, nil if the call is
+// not traced. pool tells if the call waits for a connection of the pool, the
+// wait being measured until the first call made on the connection.
+func (t dbTracing) start(ctx context.Context, name, query string, pool bool) (*dbSpan, context.Context) {
+	options := optionsFromContext(ctx, t.options)
+	if !traced(ctx, t.tracer, options) {
+		return nil, ctx
+	}
+
+	span, ctx := t.tracer.StartSpan(ctx, spanName(name, options), SpanOptions{
+		RemoteEndpoint: remoteEndpoint(options.RemoteEndpoint),
+	})
+	if query != "" {
+		span.Tag("sql.query.fingerprint", fingerprint(query))
+		if options.TagQuery {
+			setSpanQuery(span, query, options)
+		}
+	}
+	setSpanDefaultTags(span, options.DefaultTags)
+	setSpanCaller(span, options)
+
+	s := &dbSpan{span: span}
+	if pool {
+		s.call = &dbCall{start: time.Now()}
+		ctx = context.WithValue(ctx, dbCallCtxKey{}, s.call)
+	}
+	return s, ctx
+}
+
+// dbSpan is a logical span.
+type dbSpan struct {
+	span Span
+	call *dbCall
+}
+
+// finish ends the span, if any, with err.
+func (s *dbSpan) finish(err error) {
+	if s == nil {
+		return
+	}
+
+	if s.call != nil {
+		if wait, ok := s.call.wait(); ok {
+			s.span.Tag("sql.pool.wait", wait.String())
+		}
+	}
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		s.span.Tag("sql.no_rows", "true")
+	default:
+		setSpanError(s.span, err)
+	}
+	s.span.Finish()
+}
@@ -0,0 +1,183 @@
+//go:build go1.15
+// +build go1.15
+
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+func findSpan(t *testing.T, spans []model.SpanModel, name string) model.SpanModel {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("span %s not found", name)
+	return model.SpanModel{}
+}
+
+func TestDBQueryRow(t *testing.T) {
+	db, tracer, recorder := createDB(t, WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	tdb := NewDB(db, tracer, WithAllowRootSpan(true), WithTagQuery(true))
+
+	var n int
+	if err := tdb.QueryRowContext(context.Background(), "SELECT 1 WHERE 1 = ?", 2).Scan(&n); err != sql.ErrNoRows {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := recorder.Flush()
+	row, query := findSpan(t, spans, "db/query_row"), findSpan(t, spans, "sql/query")
+	if query.ParentID == nil || *query.ParentID != row.ID {
+		t.Errorf("unexpected parent, want: %s, have: %v", row.ID, query.ParentID)
+	}
+	if want, have := "true", row.Tags["sql.no_rows"]; want != have {
+		t.Errorf("unexpected no rows tag, want: %q, have: %q", want, have)
+	}
+	if _, ok := row.Tags["error"]; ok {
+		t.Errorf("unexpected error tag: %q", row.Tags["error"])
+	}
+	if want, have := "SELECT 1 WHERE 1 = ?", row.Tags["sql.query"]; want != have {
+		t.Errorf("unexpected query tag, want: %q, have: %q", want, have)
+	}
+	if _, ok := row.Tags["sql.pool.wait"]; !ok {
+		t.Error("expected the pool wait to be tagged")
+	}
+
+	// scan errors are only seen by the logical span
+	if err := tdb.QueryRow("SELECT 'abc'").Scan(&n); err == nil {
+		t.Fatal("expected error")
+	}
+
+	spans = recorder.Flush()
+	if _, ok := findSpan(t, spans, "db/query_row").Tags["error"]; !ok {
+		t.Error("expected the scan error to be tagged")
+	}
+	if _, ok := findSpan(t, spans, "sql/query").Tags["error"]; ok {
+		t.Error("unexpected error tag on the driver span")
+	}
+}
+
+func TestDBQuery(t *testing.T) {
+	db, tracer, recorder := createDB(t)
+	defer db.Close()
+	defer recorder.Close()
+
+	tdb := NewDB(db, tracer)
+
+	// not traced without a parent span
+	rows, err := tdb.Query("SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+	if want, have := 0, len(recorder.Flush()); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+
+	root, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	rows, err = tdb.QueryContext(ctx, "SELECT 1 UNION SELECT 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	var n int
+	for rows.Next() {
+		if err = rows.Scan(&n); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+	rows.Close()
+	root.Finish()
+
+	spans := recorder.Flush()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	query := findSpan(t, spans, "db/query")
+	if want, have := root.Context().ID, *query.ParentID; want != have {
+		t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+	}
+	if want, have := query.ID, *findSpan(t, spans, "sql/query").ParentID; want != have {
+		t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+	}
+}
+
+func TestDBTx(t *testing.T) {
+	db, tracer, recorder := createDB(t, WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	tdb := NewDB(db, tracer, WithAllowRootSpan(true))
+
+	tx, err := tdb.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err = tx.Exec("SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans := recorder.Flush()
+	for _, name := range []string{"db/begin_transaction", "db/exec", "db/commit"} {
+		findSpan(t, spans, name)
+	}
+	begin := findSpan(t, spans, "db/begin_transaction")
+	if want, have := begin.ID, *findSpan(t, spans, "sql/begin_transaction").ParentID; want != have {
+		t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+	}
+	if want, have := findSpan(t, spans, "db/exec").ID, *findSpan(t, spans, "sql/exec").ParentID; want != have {
+		t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+	}
+	if _, ok := findSpan(t, spans, "db/exec").Tags["sql.pool.wait"]; ok {
+		t.Error("unexpected pool wait tag within a transaction")
+	}
+	if want, have := findSpan(t, spans, "db/commit").ID, *findSpan(t, spans, "sql/commit").ParentID; want != have {
+		t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+	}
+
+	if tx, err = tdb.Begin(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans = recorder.Flush()
+	if want, have := findSpan(t, spans, "db/rollback").ID, *findSpan(t, spans, "sql/rollback").ParentID; want != have {
+		t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+	}
+}
+
+func TestDBConn(t *testing.T) {
+	db, tracer, recorder := createDB(t, WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	tdb := NewDB(db, tracer, WithAllowRootSpan(true))
+
+	conn, err := tdb.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err = conn.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	conn.Close()
+
+	spans := recorder.Flush()
+	if _, ok := findSpan(t, spans, "db/conn").Tags["sql.pool.wait"]; !ok {
+		t.Error("expected the pool wait to be tagged")
+	}
+	findSpan(t, spans, "db/exec")
+}
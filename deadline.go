@@ -0,0 +1,91 @@
+package zipkinsql
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// ctxWatch inspects the context of a call so spans can report the deadline
+// budget left when the call started and, if the context expired during the
+// call, how long the driver took to honor it.
+type ctxWatch struct {
+	ctx         context.Context
+	deadline    time.Time
+	hasDeadline bool
+	remaining   time.Duration
+	// doneAt, stopc and exitc are only set when watching the cancellations,
+	// see TagCancelLatency.
+	doneAt int64
+	stopc  chan struct{}
+	exitc  chan struct{}
+}
+
+// watchContext starts inspecting ctx. The time a cancellation happens can only
+// be known by watching ctx from another goroutine, which is only done when
+// cancelLatency is set: the latency of the deadlines is computed from the
+// deadline once the call returned.
+func watchContext(ctx context.Context, cancelLatency bool) *ctxWatch {
+	w := &ctxWatch{ctx: ctx}
+	w.deadline, w.hasDeadline = ctx.Deadline()
+	if w.hasDeadline {
+		w.remaining = time.Until(w.deadline)
+	}
+
+	if done := ctx.Done(); cancelLatency && done != nil {
+		w.stopc = make(chan struct{})
+		w.exitc = make(chan struct{})
+		go func() {
+			defer close(w.exitc)
+			select {
+			case <-done:
+			case <-w.stopc:
+				// the call might have returned because of the context before
+				// this goroutine had a chance to observe it.
+				select {
+				case <-done:
+				default:
+					return
+				}
+			}
+			atomic.StoreInt64(&w.doneAt, time.Now().UnixNano())
+		}()
+	}
+
+	return w
+}
+
+// stop releases the resources used for watching the context. It must be
+// called once the call returned.
+func (w *ctxWatch) stop() {
+	if w.stopc != nil {
+		close(w.stopc)
+		<-w.exitc
+	}
+}
+
+// tag records the deadline budget and, when err was caused by the context
+// expiring, whether it was a deadline or a cancellation and the time elapsed
+// between the context being done and the driver returning.
+func (w *ctxWatch) tag(span Span, err error) {
+	if w.hasDeadline {
+		span.Tag("sql.deadline.remaining", w.remaining.String())
+	}
+
+	if err == nil {
+		return
+	}
+
+	switch w.ctx.Err() {
+	case context.DeadlineExceeded:
+		span.Tag("sql.context.error", "deadline_exceeded")
+		if w.hasDeadline {
+			span.Tag("sql.context.done_latency", time.Since(w.deadline).String())
+		}
+	case context.Canceled:
+		span.Tag("sql.context.error", "canceled")
+		if doneAt := atomic.LoadInt64(&w.doneAt); doneAt > 0 {
+			span.Tag("sql.context.done_latency", time.Since(time.Unix(0, doneAt)).String())
+		}
+	}
+}
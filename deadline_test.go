@@ -0,0 +1,98 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestExecContextDeadline(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ctx         func() (context.Context, context.CancelFunc)
+		opts        []TraceOption
+		hasDeadline bool
+		ctxErr      string
+		hasLatency  bool
+	}{
+		{
+			name: "deadline exceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			hasDeadline: true,
+			ctxErr:      "deadline_exceeded",
+			hasLatency:  true,
+		},
+		{
+			name: "canceled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			opts:        []TraceOption{WithTagCancelLatency(true)},
+			hasDeadline: false,
+			ctxErr:      "canceled",
+			hasLatency:  true,
+		},
+		{
+			name: "canceled without latency",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			hasDeadline: false,
+			ctxErr:      "canceled",
+			hasLatency:  false,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			reporter := zipkinreporter.NewReporter()
+			defer reporter.Close()
+			tracer, _ := zipkin.NewTracer(reporter)
+
+			// exec calls block until their context is done
+			d := &fakeDriver{exec: func(ctx context.Context, _ string, _ bool) (driver.Result, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}}
+			db := sql.OpenDB(WrapConnector(d, tracer, append(c.opts, WithAllowRootSpan(true))...))
+			defer db.Close()
+
+			ctx, cancel := c.ctx()
+			defer cancel()
+
+			if _, err := db.ExecContext(ctx, "UPDATE foo SET bar = 1"); err == nil {
+				t.Fatal("expected error")
+			}
+
+			spans := reporter.Flush()
+			if want, have := 1, len(spans); want != have {
+				t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+			}
+
+			if _, ok := spans[0].Tags["sql.deadline.remaining"]; ok != c.hasDeadline {
+				t.Errorf("unexpected presence of sql.deadline.remaining tag: %t", ok)
+			}
+			if want, have := c.ctxErr, spans[0].Tags["sql.context.error"]; want != have {
+				t.Errorf("unexpected context error tag, want: %q, have: %q", want, have)
+			}
+			latency, ok := spans[0].Tags["sql.context.done_latency"]
+			if ok != c.hasLatency {
+				t.Fatalf("unexpected presence of sql.context.done_latency tag: %t", ok)
+			}
+			if _, err := time.ParseDuration(latency); ok && err != nil {
+				t.Errorf("unexpected context done latency tag: %s", err.Error())
+			}
+		})
+	}
+}
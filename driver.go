@@ -4,13 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"reflect"
 	"strconv"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	zipkin "github.com/openzipkin/zipkin-go"
-	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 )
 
 type conn interface {
@@ -22,6 +25,20 @@ type conn interface {
 	driver.Conn
 	driver.ConnPrepareContext
 	driver.ConnBeginTx
+	Unwrap() driver.Conn
+}
+
+// driverUnwrapper and stmtUnwrapper are embedded by the structs wrapDriver and
+// wrapStmt return, which then keep the Unwrap method of the wrapped zDriver and
+// zStmt.
+type driverUnwrapper interface {
+	driver.Driver
+	Unwrap() driver.Driver
+}
+
+type stmtUnwrapper interface {
+	driver.Stmt
+	Unwrap() driver.Stmt
 }
 
 var (
@@ -36,6 +53,8 @@ var (
 
 var (
 	regMu sync.Mutex
+	// registered holds the drivers wrapped by RegisterDriver by name.
+	registered = make(map[string]driver.Driver)
 )
 
 // Register initializes and registers our zipkinsql wrapped database driver
@@ -43,7 +62,12 @@ var (
 // returns the generated driverName to use when calling sql.Open.
 // It is possible to register multiple wrappers for the same database driver if
 // needing different TraceOptions for different connections.
+// An error is returned if neither tracer nor the Tracer option is set.
 func Register(driverName string, tracer *zipkin.Tracer, options ...TraceOption) (string, error) {
+	if err := checkTracer(tracer, options); err != nil {
+		return "", err
+	}
+
 	// retrieve the driver implementation we need to wrap with instrumentation
 	db, err := sql.Open(driverName, "")
 	if err != nil {
@@ -62,7 +86,50 @@ func Register(driverName string, tracer *zipkin.Tracer, options ...TraceOption)
 	return registerName, nil
 }
 
+// RegisterDriver registers the database driver d wrapped with zipkinsql under
+// name, to use when calling sql.Open. Unlike Register, the driver doesn't need
+// to be registered with database/sql and the name doesn't depend on the
+// registration order.
+// Registering the same driver again under the same name does nothing, the
+// options of the first registration being kept, and returns name. An error is
+// returned if name is already used by another driver or if neither tracer nor
+// the Tracer option is set.
+func RegisterDriver(name string, d driver.Driver, tracer *zipkin.Tracer, options ...TraceOption) (string, error) {
+	if d == nil {
+		return "", fmt.Errorf("zipkinsql: driver %q is nil", name)
+	}
+	if err := checkTracer(tracer, options); err != nil {
+		return "", err
+	}
+
+	regMu.Lock()
+	defer regMu.Unlock()
+	if parent, ok := registered[name]; ok {
+		if !sameDriver(parent, d) {
+			return "", fmt.Errorf("zipkinsql: driver %q is already registered for %T", name, parent)
+		}
+		return name, nil
+	}
+	for _, n := range sql.Drivers() {
+		if n == name {
+			return "", fmt.Errorf("zipkinsql: driver %q is already registered", name)
+		}
+	}
+
+	sql.Register(name, Wrap(d, tracer, options...))
+	registered[name] = d
+	return name, nil
+}
+
+// sameDriver tells if a and b are the same driver, without panicking on
+// driver types which can't be compared.
+func sameDriver(a, b driver.Driver) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
 // Wrap takes a SQL driver and wraps it with Zipkin instrumentation.
+// It panics if neither t nor the Tracer option is set.
 func Wrap(d driver.Driver, t *zipkin.Tracer, options ...TraceOption) driver.Driver {
 	o := TraceOptions{}
 	for _, option := range options {
@@ -72,11 +139,20 @@ func Wrap(d driver.Driver, t *zipkin.Tracer, options ...TraceOption) driver.Driv
 		o.TagQueryParams = false
 	}
 
-	return wrapDriver(d, t, o)
+	return wrapDriver(d, newTracer(t, o), o)
+}
+
+// Unwrap returns the wrapped driver.Driver.
+func (d zDriver) Unwrap() driver.Driver {
+	return d.parent
 }
 
 func (d zDriver) Open(name string) (driver.Conn, error) {
-	c, err := d.parent.Open(name)
+	var c driver.Conn
+	err := intercept(context.Background(), d.options.Interceptors, &Call{Op: OpConnect}, func(context.Context) (err error) {
+		c, err = d.parent.Open(name)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -84,77 +160,71 @@ func (d zDriver) Open(name string) (driver.Conn, error) {
 }
 
 // WrapConn allows an existing driver.Conn to be wrapped by zipkinsql.
+// It panics if neither t nor the Tracer option is set.
 func WrapConn(c driver.Conn, t *zipkin.Tracer, options ...TraceOption) driver.Conn {
 	o := TraceOptions{}
 	for _, option := range options {
 		option(&o)
 	}
-	return wrapConn(c, t, o)
+	return wrapConn(c, newTracer(t, o), o)
+}
+
+// UnwrapConn returns the driver.Conn wrapped by zipkinsql, e.g. to type assert
+// it to the connection type of the driver within sql.Conn.Raw and reach its
+// vendor specific features. Connections not wrapped by zipkinsql are returned
+// as is.
+func UnwrapConn(c driver.Conn) driver.Conn {
+	for {
+		u, ok := c.(interface{ Unwrap() driver.Conn })
+		if !ok {
+			return c
+		}
+		c = u.Unwrap()
+	}
 }
 
 // zConn implements driver.Conn
 type zConn struct {
 	parent  driver.Conn
-	tracer  *zipkin.Tracer
+	tracer  Tracer
 	options TraceOptions
+	// txTags holds the tags applying to every call made within the ongoing
+	// transaction, if any.
+	txTags map[string]string
 }
 
 func (c zConn) Ping(ctx context.Context) (err error) {
 	if pinger, ok := c.parent.(driver.Pinger); ok {
-		err = pinger.Ping(ctx)
+		err = intercept(ctx, c.traceOptions(ctx).Interceptors, &Call{Op: OpPing}, pinger.Ping)
 	}
 	return
 }
 
 func (c zConn) Exec(query string, args []driver.Value) (res driver.Result, err error) {
 	if exec, ok := c.parent.(driver.Execer); ok {
-		return exec.Exec(query, args)
+		if len(c.options.Interceptors) == 0 {
+			return exec.Exec(query, args)
+		}
+		return interceptExec(context.Background(), c.options.Interceptors, query, valuesToNamedValues(args), func(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+			return exec.Exec(query, namedValuesToValues(args))
+		})
 	}
 
 	return nil, driver.ErrSkip
 }
 
 func (c zConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
+	options := c.traceOptions(ctx)
+	isTraced := traced(ctx, c.tracer, options)
+	fp := callFingerprint(query, isTraced, options)
+	defer recordMetrics(options.Metrics, "sql/exec", fp, time.Now(), &err)
 	if execCtx, ok := c.parent.(driver.ExecerContext); ok {
-		parentSpan := zipkin.SpanFromContext(ctx)
-		if parentSpan == nil && !c.options.AllowRootSpan {
-			return execCtx.ExecContext(ctx, query, args)
+		fault := pickFault(options.Faults, query)
+		var tracing *spanInterceptor
+		if isTraced {
+			tracing = &spanInterceptor{tracer: c.tracer, options: options, name: "sql/exec", fingerprint: fp, fault: fault}
 		}
-
-		var (
-			startTime time.Time
-		)
-
-		defer func() {
-			if err == nil || err != driver.ErrSkip {
-				var span zipkin.Span
-				span, _ = c.tracer.StartSpanFromContext(
-					ctx,
-					"sql/exec",
-					zipkin.Kind(zipkinmodel.Client),
-					zipkin.StartTime(startTime),
-					zipkin.RemoteEndpoint(c.options.RemoteEndpoint),
-				)
-
-				if c.options.TagQuery {
-					span.Tag("sql.query", query)
-					if c.options.TagQueryParams {
-						addNamedParamsTags(span, args)
-					}
-				}
-				setSpanDefaultTags(span, c.options.DefaultTags)
-
-				setSpanError(span, err)
-				span.Finish()
-			}
-		}()
-
-		startTime = time.Now()
-		if res, err = execCtx.ExecContext(ctx, query, args); err != nil {
-			return nil, err
-		}
-
-		return zResult{parent: res, tracer: c.tracer, ctx: ctx, options: c.options}, nil
+		return interceptExec(ctx, callInterceptors(tracing, fault, options.Interceptors), query, args, execCtx.ExecContext)
 	}
 
 	return nil, driver.ErrSkip
@@ -162,186 +232,166 @@ func (c zConn) ExecContext(ctx context.Context, query string, args []driver.Name
 
 func (c zConn) Query(query string, args []driver.Value) (rows driver.Rows, err error) {
 	if queryer, ok := c.parent.(driver.Queryer); ok {
-		return queryer.Query(query, args)
+		if len(c.options.Interceptors) == 0 {
+			return queryer.Query(query, args)
+		}
+		return interceptQuery(context.Background(), c.options.Interceptors, query, valuesToNamedValues(args), func(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+			return queryer.Query(query, namedValuesToValues(args))
+		})
 	}
 
 	return nil, driver.ErrSkip
 }
 
 func (c zConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	options := c.traceOptions(ctx)
+	isTraced := traced(ctx, c.tracer, options)
+	fp := callFingerprint(query, isTraced, options)
+	defer recordMetrics(options.Metrics, "sql/query", fp, time.Now(), &err)
 	if queryerCtx, ok := c.parent.(driver.QueryerContext); ok {
-		parentSpan := zipkin.SpanFromContext(ctx)
-		if parentSpan == nil && !c.options.AllowRootSpan {
-			return queryerCtx.QueryContext(ctx, query, args)
+		fault := pickFault(options.Faults, query)
+		var tracing *spanInterceptor
+		if isTraced {
+			tracing = &spanInterceptor{tracer: c.tracer, options: options, name: "sql/query", fingerprint: fp, fault: fault}
 		}
-
-		var (
-			startTime time.Time
-		)
-
-		defer func() {
-			if err == nil || err != driver.ErrSkip {
-				var span zipkin.Span
-				span, _ = c.tracer.StartSpanFromContext(
-					ctx,
-					"sql/query",
-					zipkin.Kind(zipkinmodel.Client),
-					zipkin.StartTime(startTime),
-					zipkin.RemoteEndpoint(c.options.RemoteEndpoint),
-				)
-
-				if c.options.TagQuery {
-					span.Tag("sql.query", query)
-					if c.options.TagQueryParams {
-						addNamedParamsTags(span, args)
-					}
-				}
-				setSpanDefaultTags(span, c.options.DefaultTags)
-
-				setSpanError(span, err)
-				span.Finish()
-			}
-		}()
-
-		startTime = time.Now()
-		if rows, err = queryerCtx.QueryContext(ctx, query, args); err != nil {
-			return nil, err
-		}
-
-		return rows, nil
+		return interceptQuery(ctx, callInterceptors(tracing, fault, options.Interceptors), query, args, queryerCtx.QueryContext)
 	}
 
 	return nil, driver.ErrSkip
 }
 
-func (c zConn) Prepare(query string) (stmt driver.Stmt, err error) {
-	if c.options.AllowRootSpan {
-		span := c.tracer.StartSpan(
-			"sql/prepare",
-			zipkin.Kind(zipkinmodel.Client),
-			zipkin.RemoteEndpoint(c.options.RemoteEndpoint),
-		)
-
-		if c.options.TagQuery {
-			span.Tag("sql.query", query)
-		}
-		setSpanDefaultTags(span, c.options.DefaultTags)
-		defer func() {
-			setSpanError(span, err)
-			span.Finish()
-		}()
+func (c *zConn) Prepare(query string) (stmt driver.Stmt, err error) {
+	fp := fingerprint(query)
+	defer recordMetrics(c.options.Metrics, "sql/prepare", fp, time.Now(), &err)
+	var tracing *spanInterceptor
+	if traced(context.Background(), c.tracer, c.options) {
+		tracing = &spanInterceptor{tracer: c.tracer, options: c.options, name: "sql/prepare", fingerprint: fp, noContext: true}
 	}
 
-	stmt, err = c.parent.Prepare(query)
+	stmt, err = interceptPrepare(context.Background(), callInterceptors(tracing, nil, c.options.Interceptors), query, func(_ context.Context, query string) (driver.Stmt, error) {
+		return c.parent.Prepare(query)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	stmt = wrapStmt(stmt, query, c.tracer, c.options)
-	return
+	return wrapStmt(stmt, query, fp, tracing.spanOrNil(), c.options, c), nil
 }
 
-func (c *zConn) Close() error {
-	return c.parent.Close()
+// Unwrap returns the wrapped driver.Conn.
+func (c *zConn) Unwrap() driver.Conn {
+	return c.parent
 }
 
-func (c *zConn) Begin() (driver.Tx, error) {
-	return c.Begin()
+func (c *zConn) Close() error {
+	return c.parent.Close()
 }
 
-func (c *zConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
-	var span zipkin.Span
-	setSpanDefaultTags(span, c.options.DefaultTags)
-	if c.options.AllowRootSpan || zipkin.SpanFromContext(ctx) != nil {
-		span, ctx = c.tracer.StartSpanFromContext(
-			ctx,
-			"sql/prepare",
-			zipkin.Kind(zipkinmodel.Client),
-			zipkin.RemoteEndpoint(c.options.RemoteEndpoint),
-		)
-		if c.options.TagQuery {
-			span.Tag("sql.query", query)
-		}
-
-		defer func() {
-			setSpanError(span, err)
-			span.Finish()
-		}()
-	}
-
-	if prepCtx, ok := c.parent.(driver.ConnPrepareContext); ok {
-		stmt, err = prepCtx.PrepareContext(ctx, query)
-	} else {
-		stmt, err = c.parent.Prepare(query)
+func (c *zConn) Begin() (tx driver.Tx, err error) {
+	ctx := context.Background()
+	defer recordMetrics(c.options.Metrics, "sql/begin_transaction", "", time.Now(), &err)
+	isTraced := traced(ctx, c.tracer, c.options)
+	var tracing *spanInterceptor
+	if isTraced {
+		tracing = &spanInterceptor{tracer: c.tracer, options: c.options, name: "sql/begin_transaction", noContext: true}
 	}
 
+	err = intercept(ctx, callInterceptors(tracing, nil, c.options.Interceptors), &Call{Op: OpBegin}, func(context.Context) (err error) {
+		tx, err = c.parent.Begin()
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	if !isTraced && len(c.options.Interceptors) == 0 {
+		return tx, nil
+	}
 
-	stmt = wrapStmt(stmt, query, c.tracer, c.options)
-	return
+	return zTx{parent: tx, conn: c, ctx: ctx, tracer: c.tracer, options: c.options}, nil
 }
 
-func (c *zConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	if zipkin.SpanFromContext(ctx) == nil && !c.options.AllowRootSpan {
-		if connBeginTx, ok := c.parent.(driver.ConnBeginTx); ok {
-			return connBeginTx.BeginTx(ctx, opts)
-		}
+func (c *zConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	options := c.traceOptions(ctx)
+	fp := fingerprint(query)
+	defer recordMetrics(options.Metrics, "sql/prepare", fp, time.Now(), &err)
+	fault := pickFault(options.Faults, query)
+	var tracing *spanInterceptor
+	if traced(ctx, c.tracer, options) {
+		tracing = &spanInterceptor{tracer: c.tracer, options: options, name: "sql/prepare", fingerprint: fp, fault: fault}
+	}
 
-		return c.parent.Begin()
+	stmt, err = interceptPrepare(ctx, callInterceptors(tracing, fault, options.Interceptors), query, c.prepare)
+	if err != nil {
+		return nil, err
 	}
 
-	span, _ := c.tracer.StartSpanFromContext(
-		ctx,
-		"sql/begin_transaction",
-		zipkin.Kind(zipkinmodel.Client),
-		zipkin.RemoteEndpoint(c.options.RemoteEndpoint),
-	)
-	defer span.Finish()
+	return wrapStmt(stmt, query, fp, tracing.spanOrNil(), options, c), nil
+}
 
-	setSpanDefaultTags(span, c.options.DefaultTags)
+func (c *zConn) prepare(ctx context.Context, query string) (driver.Stmt, error) {
+	if prepCtx, ok := c.parent.(driver.ConnPrepareContext); ok {
+		return prepCtx.PrepareContext(ctx, query)
+	}
+	return c.parent.Prepare(query)
+}
 
-	if connBeginTx, ok := c.parent.(driver.ConnBeginTx); ok {
-		tx, err := connBeginTx.BeginTx(ctx, opts)
-		setSpanError(span, err)
+func (c *zConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	c.txTags = txTagsFromContext(ctx)
+	defer func() {
 		if err != nil {
-			return nil, err
+			c.txTags = nil
 		}
-		return zTx{parent: tx, ctx: ctx, tracer: c.tracer, options: c.options}, nil
+	}()
+
+	options := c.traceOptions(ctx)
+	defer recordMetrics(options.Metrics, "sql/begin_transaction", "", time.Now(), &err)
+	fault := pickFault(options.Faults, "")
+	isTraced := traced(ctx, c.tracer, options)
+	var tracing *spanInterceptor
+	if isTraced {
+		tracing = &spanInterceptor{tracer: c.tracer, options: options, name: "sql/begin_transaction", fault: fault}
 	}
 
-	tx, err := c.parent.Begin()
-	setSpanError(span, err)
+	err = intercept(ctx, callInterceptors(tracing, fault, options.Interceptors), &Call{Op: OpBegin}, func(ctx context.Context) (err error) {
+		if connBeginTx, ok := c.parent.(driver.ConnBeginTx); ok {
+			tx, err = connBeginTx.BeginTx(ctx, opts)
+		} else {
+			tx, err = c.parent.Begin()
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	if !isTraced && c.txTags == nil && len(options.Interceptors) == 0 {
+		return tx, nil
+	}
 
-	return zTx{parent: tx, ctx: ctx, tracer: c.tracer, options: c.options}, nil
+	// the transaction tags must be dropped once it ends and the commit or
+	// rollback must be traced and intercepted
+	return zTx{parent: tx, conn: c, ctx: ctx, tracer: c.tracer, options: options}, nil
 }
 
 // zResult implements driver.Result
 type zResult struct {
 	parent  driver.Result
 	ctx     context.Context
-	tracer  *zipkin.Tracer
+	tracer  Tracer
 	options TraceOptions
 }
 
 func (r zResult) LastInsertId() (int64, error) {
-	if !r.options.LastInsertIDSpan {
+	if !r.options.LastInsertIDSpan || r.options.tracingDisabled {
 		return r.parent.LastInsertId()
 	}
 
-	span, _ := r.tracer.StartSpanFromContext(
-		r.ctx,
-		"sql/last_insert_id",
-		zipkin.Kind(zipkinmodel.Client),
-		zipkin.RemoteEndpoint(r.options.RemoteEndpoint),
-	)
+	span, _ := r.tracer.StartSpan(r.ctx, spanName("sql/last_insert_id", r.options), SpanOptions{
+		RemoteEndpoint: remoteEndpoint(r.options.RemoteEndpoint),
+	})
 	defer span.Finish()
 
 	setSpanDefaultTags(span, r.options.DefaultTags)
+	setSpanCaller(span, r.options)
 
 	id, err := r.parent.LastInsertId()
 	setSpanError(span, err)
@@ -350,13 +400,10 @@ func (r zResult) LastInsertId() (int64, error) {
 }
 
 func (r zResult) RowsAffected() (cnt int64, err error) {
-	zipkin.SpanFromContext(r.ctx)
-	if r.options.RowsAffectedSpan && (r.options.AllowRootSpan || zipkin.SpanFromContext(r.ctx) != nil) {
-		span, _ := r.tracer.StartSpanFromContext(
-			r.ctx,
-			"sql/rows_affected",
-		)
+	if r.options.RowsAffectedSpan && traced(r.ctx, r.tracer, r.options) {
+		span, _ := r.tracer.StartSpan(r.ctx, spanName("sql/rows_affected", r.options), SpanOptions{})
 		setSpanDefaultTags(span, r.options.DefaultTags)
+		setSpanCaller(span, r.options)
 		defer func() {
 			span.Tag("sql.affected_rows", fmt.Sprintf("%d", cnt))
 			setSpanError(span, err)
@@ -370,50 +417,50 @@ func (r zResult) RowsAffected() (cnt int64, err error) {
 
 // zStmt implements driver.Stmt
 type zStmt struct {
-	parent  driver.Stmt
-	query   string
-	tracer  *zipkin.Tracer
-	options TraceOptions
+	parent      driver.Stmt
+	query       string
+	fingerprint string
+	conn        *zConn
+	// prepareSpan is the span of the prepare call, if it was traced, which the
+	// spans of the non context methods relate to.
+	prepareSpan Span
+	tracer      Tracer
+	options     TraceOptions
+}
+
+// legacyTracing returns the interceptor tracing a call of a non context method
+// named name, nil if the call must not be traced, as when tracing was disabled
+// for the prepare call. The spans are children of the prepare span, if it was
+// traced, or root spans linked to it when LinkPreparedStmtSpans is set.
+func (s zStmt) legacyTracing(name string) *spanInterceptor {
+	tracing := &spanInterceptor{tracer: s.tracer, options: s.options, name: name, fingerprint: s.fingerprint, noContext: true, stmt: true}
+	switch {
+	case s.prepareSpan == nil:
+		if !traced(context.Background(), s.tracer, s.options) {
+			return nil
+		}
+	case s.options.LinkPreparedStmtSpans:
+		tracing.spanOptions.Links = []SpanContext{s.prepareSpan.SpanContext()}
+	default:
+		tracing.spanOptions.Parent = s.prepareSpan
+	}
+	return tracing
 }
 
 func (s zStmt) Exec(args []driver.Value) (res driver.Result, err error) {
-	if !s.options.AllowRootSpan {
+	defer recordMetrics(s.options.Metrics, "sql/exec", s.fingerprint, time.Now(), &err)
+	interceptors := callInterceptors(s.legacyTracing("sql:exec"), nil, s.options.Interceptors)
+	if len(interceptors) == 0 {
 		return s.parent.Exec(args)
 	}
+	return interceptExec(context.Background(), interceptors, s.query, valuesToNamedValues(args), func(_ context.Context, _ string, args []driver.NamedValue) (driver.Result, error) {
+		return s.parent.Exec(namedValuesToValues(args))
+	})
+}
 
-	span, ctx := s.tracer.StartSpanFromContext(
-		context.Background(),
-		"sql:exec",
-		zipkin.Kind(zipkinmodel.Client),
-		zipkin.RemoteEndpoint(s.options.RemoteEndpoint),
-	)
-	setSpanDefaultTags(span, s.options.DefaultTags)
-
-	if s.options.TagQuery {
-		span.Tag("sql.query", s.query)
-		if s.options.TagQueryParams {
-			addParamsTags(span, args)
-		}
-	}
-
-	defer func() {
-		setSpanError(span, err)
-		span.Finish()
-	}()
-
-	res, err = s.parent.Exec(args)
-	if err != nil {
-		return nil, err
-	}
-	if s.options.TagAffectedRows {
-		if affectedRows, aRErr := res.RowsAffected(); aRErr != nil {
-			span.Tag("sql.affected_rows", fmt.Sprintf("%d", affectedRows))
-		}
-	}
-
-	res, err = zResult{parent: res, ctx: ctx, tracer: s.tracer, options: s.options}, nil
-
-	return
+// Unwrap returns the wrapped driver.Stmt.
+func (s zStmt) Unwrap() driver.Stmt {
+	return s.parent
 }
 
 func (s zStmt) Close() error {
@@ -425,215 +472,251 @@ func (s zStmt) NumInput() int {
 }
 
 func (s zStmt) Query(args []driver.Value) (rows driver.Rows, err error) {
-	if !s.options.AllowRootSpan {
+	defer recordMetrics(s.options.Metrics, "sql/query", s.fingerprint, time.Now(), &err)
+	interceptors := callInterceptors(s.legacyTracing("sql:query"), nil, s.options.Interceptors)
+	if len(interceptors) == 0 {
 		return s.parent.Query(args)
 	}
-
-	span, _ := s.tracer.StartSpanFromContext(
-		context.Background(),
-		"sql:query",
-		zipkin.Kind(zipkinmodel.Client),
-		zipkin.RemoteEndpoint(s.options.RemoteEndpoint),
-	)
-	setSpanDefaultTags(span, s.options.DefaultTags)
-
-	if s.options.TagQuery {
-		span.Tag("sql.query", s.query)
-		if s.options.TagQueryParams {
-			addParamsTags(span, args)
-		}
-	}
-
-	defer func() {
-		setSpanError(span, err)
-		span.Finish()
-	}()
-
-	rows, err = s.parent.Query(args)
-	if err != nil {
-		return nil, err
-	}
-
-	return
+	return interceptQuery(context.Background(), interceptors, s.query, valuesToNamedValues(args), func(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+		return s.parent.Query(namedValuesToValues(args))
+	})
 }
 
 func (s zStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
-	if zipkin.SpanFromContext(ctx) == nil && !s.options.AllowRootSpan {
-		return s.parent.(driver.StmtExecContext).ExecContext(ctx, args)
-	}
-
-	span, ctx := s.tracer.StartSpanFromContext(
-		ctx,
-		"sql/exec",
-		zipkin.Kind(zipkinmodel.Client),
-		zipkin.RemoteEndpoint(s.options.RemoteEndpoint),
-	)
-	defer func() {
-		setSpanError(span, err)
-		span.Finish()
-	}()
-
-	if s.options.TagQuery {
-		span.Tag("sql.query", s.query)
-		if s.options.TagQueryParams {
-			addNamedParamsTags(span, args)
-		}
+	options := s.conn.traceOptions(ctx)
+	defer recordMetrics(options.Metrics, "sql/exec", s.fingerprint, time.Now(), &err)
+	fault := pickFault(options.Faults, s.query)
+	var tracing *spanInterceptor
+	if traced(ctx, s.tracer, options) {
+		tracing = &spanInterceptor{tracer: s.tracer, options: options, name: "sql/exec", fingerprint: s.fingerprint, fault: fault, stmt: true}
 	}
 
-	setSpanDefaultTags(span, s.options.DefaultTags)
-
-	execContext := s.parent.(driver.StmtExecContext)
-	res, err = execContext.ExecContext(ctx, args)
-	if err != nil {
-		return nil, err
-	}
-	if s.options.TagAffectedRows {
-		if affectedRows, aRErr := res.RowsAffected(); aRErr != nil {
-			span.Tag("sql.affected_rows", fmt.Sprintf("%d", affectedRows))
-		}
-	}
-
-	res, err = zResult{parent: res, tracer: s.tracer, ctx: ctx, options: s.options}, nil
-	return
+	return interceptExec(ctx, callInterceptors(tracing, fault, options.Interceptors), s.query, args, s.execContext)
 }
 
 func (s zStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
-	if zipkin.SpanFromContext(ctx) == nil && !s.options.AllowRootSpan {
-		return s.parent.(driver.StmtQueryContext).QueryContext(ctx, args)
+	options := s.conn.traceOptions(ctx)
+	defer recordMetrics(options.Metrics, "sql/query", s.fingerprint, time.Now(), &err)
+	fault := pickFault(options.Faults, s.query)
+	var tracing *spanInterceptor
+	if traced(ctx, s.tracer, options) {
+		tracing = &spanInterceptor{tracer: s.tracer, options: options, name: "sql/query", fingerprint: s.fingerprint, fault: fault, stmt: true}
 	}
 
-	span, ctx := s.tracer.StartSpanFromContext(
-		ctx,
-		"sql/query",
-		zipkin.Kind(zipkinmodel.Client),
-		zipkin.RemoteEndpoint(s.options.RemoteEndpoint),
-	)
-	defer func() {
-		setSpanError(span, err)
-		span.Finish()
-	}()
-
-	if s.options.TagQuery {
-		span.Tag("sql.query", s.query)
-		if s.options.TagQueryParams {
-			addNamedParamsTags(span, args)
-		}
-	}
-
-	setSpanDefaultTags(span, s.options.DefaultTags)
+	return interceptQuery(ctx, callInterceptors(tracing, fault, options.Interceptors), s.query, args, s.queryContext)
+}
 
-	defer func() {
-		setSpanError(span, err)
-		span.Finish()
-	}()
+// execContext executes the statement, whose query can't be rewritten.
+func (s zStmt) execContext(ctx context.Context, _ string, args []driver.NamedValue) (driver.Result, error) {
+	return s.parent.(driver.StmtExecContext).ExecContext(ctx, args)
+}
 
+// queryContext runs the statement, whose query can't be rewritten.
+func (s zStmt) queryContext(ctx context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
 	// we already tested driver to implement StmtQueryContext
-	queryContext := s.parent.(driver.StmtQueryContext)
-	rows, err = queryContext.QueryContext(ctx, args)
-	if err != nil {
-		return nil, err
-	}
-
-	return
+	return s.parent.(driver.StmtQueryContext).QueryContext(ctx, args)
 }
 
 // zTx implemens driver.Tx
 type zTx struct {
 	parent  driver.Tx
+	conn    *zConn
 	ctx     context.Context
-	tracer  *zipkin.Tracer
+	tracer  Tracer
 	options TraceOptions
 }
 
+// Unwrap returns the wrapped driver.Tx.
+func (t zTx) Unwrap() driver.Tx {
+	return t.parent
+}
+
 func (t zTx) Commit() (err error) {
-	if zipkin.SpanFromContext(t.ctx) != nil || t.options.AllowRootSpan {
-		span, _ := t.tracer.StartSpanFromContext(
-			t.ctx,
-			"sql/commit",
-			zipkin.Kind(zipkinmodel.Client),
-			zipkin.RemoteEndpoint(t.options.RemoteEndpoint),
-		)
-		defer func() {
-			setSpanDefaultTags(span, t.options.DefaultTags)
-			setSpanError(span, err)
-			span.Finish()
-		}()
-	}
-	err = t.parent.Commit()
+	defer recordMetrics(t.options.Metrics, "sql/commit", "", time.Now(), &err)
+	ctx := txEndContext(t.ctx)
+	var tracing *spanInterceptor
+	if traced(ctx, t.tracer, t.options) {
+		tracing = &spanInterceptor{tracer: t.tracer, options: t.options, name: "sql/commit", noContext: true}
+	}
+	err = intercept(ctx, callInterceptors(tracing, nil, t.options.Interceptors), &Call{Op: OpCommit}, func(context.Context) error {
+		return t.parent.Commit()
+	})
+	t.conn.txTags = nil
 	return
 }
 
 func (t zTx) Rollback() (err error) {
-	if zipkin.SpanFromContext(t.ctx) != nil || t.options.AllowRootSpan {
-		span, _ := t.tracer.StartSpanFromContext(
-			t.ctx,
-			"sql/rollback",
-			zipkin.Kind(zipkinmodel.Client),
-			zipkin.RemoteEndpoint(t.options.RemoteEndpoint),
-		)
-		defer func() {
-			setSpanDefaultTags(span, t.options.DefaultTags)
-			setSpanError(span, err)
-			span.Finish()
-		}()
-	}
-	err = t.parent.Rollback()
+	defer recordMetrics(t.options.Metrics, "sql/rollback", "", time.Now(), &err)
+	ctx := txEndContext(t.ctx)
+	var tracing *spanInterceptor
+	if traced(ctx, t.tracer, t.options) {
+		tracing = &spanInterceptor{tracer: t.tracer, options: t.options, name: "sql/rollback", noContext: true}
+	}
+	err = intercept(ctx, callInterceptors(tracing, nil, t.options.Interceptors), &Call{Op: OpRollback}, func(context.Context) error {
+		return t.parent.Rollback()
+	})
+	t.conn.txTags = nil
 	return
 }
 
-func addParamsTags(span zipkin.Span, args []driver.Value) {
+// traced tells if a call made with ctx is traced: it needs a parent span or
+// AllowRootSpan, and tracing must not be disabled for ctx.
+func traced(ctx context.Context, tracer Tracer, options TraceOptions) bool {
+	if options.tracingDisabled {
+		return false
+	}
+	return options.AllowRootSpan || tracer.SpanFromContext(ctx) != nil
+}
+
+// spanName returns the name of a span from its default name, applying the
+// SpanNameFormatter. The exec and query spans are named after
+// WithContextSpanName, if set.
+func spanName(name string, options TraceOptions) string {
+	if options.spanName != "" && (name == "sql/exec" || name == "sql/query") {
+		return options.spanName
+	}
+	if options.SpanNameFormatter != nil {
+		return options.SpanNameFormatter(name)
+	}
+	return name
+}
+
+func setSpanQuery(span Span, query string, options TraceOptions) {
+	if options.MaxQueryLength > 0 {
+		var truncated bool
+		if query, truncated = truncate(query, options.MaxQueryLength); truncated {
+			setSpanTruncated(span)
+		}
+	}
+	span.Tag("sql.query", query)
+
+	if named := options.namedQuery; named != "" {
+		if options.MaxQueryLength > 0 {
+			var truncated bool
+			if named, truncated = truncate(named, options.MaxQueryLength); truncated {
+				setSpanTruncated(span)
+			}
+		}
+		span.Tag("sql.query.named", named)
+	}
+}
+
+func addParamsTags(span Span, args []driver.Value, options TraceOptions) {
 	for i, arg := range args {
-		key := "sql.arg" + strconv.Itoa(i)
-		span.Tag(key, argToTagValue(arg))
+		key := paramTagKey(i+1, "", options)
+		if options.LegacyParamTagKeys {
+			key = "sql.arg" + strconv.Itoa(i)
+		}
+		value, truncated := argToTagValue(redactArg(driver.NamedValue{Ordinal: i + 1, Value: arg}, options), options)
+		if truncated {
+			setSpanTruncated(span)
+		}
+		span.Tag(key, value)
 	}
 }
 
-func addNamedParamsTags(span zipkin.Span, args []driver.NamedValue) {
+func addNamedParamsTags(span Span, args []driver.NamedValue, options TraceOptions) {
 	for _, arg := range args {
-		var key string
-		if arg.Name != "" {
-			key = arg.Name
-		} else {
-			key = "sql.arg." + strconv.Itoa(arg.Ordinal)
+		key := paramTagKey(arg.Ordinal, arg.Name, options)
+		if options.LegacyParamTagKeys {
+			if arg.Name != "" {
+				key = arg.Name
+			} else {
+				key = "sql.arg." + strconv.Itoa(arg.Ordinal)
+			}
+		}
+		value, truncated := argToTagValue(redactArg(arg, options), options)
+		if truncated {
+			setSpanTruncated(span)
 		}
-		span.Tag(key, argToTagValue(arg.Value))
+		span.Tag(key, value)
 	}
 }
 
-func argToTagValue(val interface{}) string {
+// redactArg returns the value to record for a query parameter.
+func redactArg(arg driver.NamedValue, options TraceOptions) interface{} {
+	if options.ArgRedactor == nil {
+		return arg.Value
+	}
+	return options.ArgRedactor(arg)
+}
+
+// paramTagKey returns the tag key of a query parameter, e.g. sql.arg.1 for the
+// first positional parameter or sql.arg.name for a named parameter.
+func paramTagKey(ordinal int, name string, options TraceOptions) string {
+	prefix := options.ParamTagPrefix
+	if prefix == "" {
+		prefix = defaultParamTagPrefix
+	}
+	if name != "" {
+		return prefix + "." + name
+	}
+	return prefix + "." + strconv.Itoa(ordinal)
+}
+
+// argToTagValue returns the tag value for a query parameter and whether it
+// was truncated to the MaxTagValueLength.
+func argToTagValue(val interface{}, options TraceOptions) (string, bool) {
+	var s string
 	switch v := val.(type) {
 	case nil:
-		return "NULL"
+		return "NULL", false
 	case int64:
-		return fmt.Sprintf("%d", v)
+		return strconv.FormatInt(v, 10), false
 	case float64:
-		return fmt.Sprintf("%f", v)
+		return strconv.FormatFloat(v, 'g', -1, 64), false
 	case bool:
-		if v {
-			return "true"
-		}
-		return "false"
+		return strconv.FormatBool(v), false
 	case []byte:
-		if len(v) > 256 {
-			v = v[0:256]
+		switch options.BinaryEncoding {
+		case BinaryHex:
+			s = hex.EncodeToString(v)
+		case BinaryBase64:
+			s = base64.StdEncoding.EncodeToString(v)
+		case BinaryLength:
+			return "[" + strconv.Itoa(len(v)) + " bytes]", false
+		default:
+			s = string(v)
 		}
-		return fmt.Sprintf("%s", v)
-	default:
-		s := fmt.Sprintf("%v", v)
-		if len(s) > 256 {
-			s = s[0:256]
+	case time.Time:
+		layout := options.TimeFormat
+		if layout == "" {
+			layout = time.RFC3339Nano
 		}
-		return s
+		s = v.Format(layout)
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+
+	maxLength := options.MaxTagValueLength
+	if maxLength <= 0 {
+		maxLength = defaultMaxTagValueLength
 	}
+	return truncate(s, maxLength)
+}
+
+// truncate cuts s to at most max bytes without splitting a UTF-8 sequence.
+func truncate(s string, max int) (string, bool) {
+	if len(s) <= max {
+		return s, false
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max], true
+}
+
+func setSpanTruncated(span Span) {
+	span.Tag("sql.truncated", "true")
 }
 
-func setSpanError(span zipkin.Span, err error) {
+func setSpanError(span Span, err error) {
 	if err != nil {
-		zipkin.TagError.Set(span, err.Error())
+		span.SetError(err)
 	}
 }
 
-func setSpanDefaultTags(span zipkin.Span, tags map[string]string) {
+func setSpanDefaultTags(span Span, tags map[string]string) {
 	for key, value := range tags {
 		span.Tag(key, value)
 	}
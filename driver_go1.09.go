@@ -5,8 +5,6 @@ package zipkinsql
 import (
 	"database/sql"
 	"database/sql/driver"
-
-	zipkin "github.com/openzipkin/zipkin-go"
 )
 
 var errConnDone = sql.ErrConnDone
@@ -14,15 +12,15 @@ var errConnDone = sql.ErrConnDone
 // zDriver implements driver.Driver
 type zDriver struct {
 	parent  driver.Driver
-	tracer  *zipkin.Tracer
+	tracer  Tracer
 	options TraceOptions
 }
 
-func wrapDriver(d driver.Driver, t *zipkin.Tracer, o TraceOptions) driver.Driver {
+func wrapDriver(d driver.Driver, t Tracer, o TraceOptions) driver.Driver {
 	return zDriver{parent: d, tracer: t, options: o}
 }
 
-func wrapConn(parent driver.Conn, t *zipkin.Tracer, options TraceOptions) driver.Conn {
+func wrapConn(parent driver.Conn, t Tracer, options TraceOptions) driver.Conn {
 	var (
 		n, hasNameValueChecker = parent.(driver.NamedValueChecker)
 	)
@@ -36,7 +34,9 @@ func wrapConn(parent driver.Conn, t *zipkin.Tracer, options TraceOptions) driver
 	return c
 }
 
-func wrapStmt(stmt driver.Stmt, query string, tracer *zipkin.Tracer, options TraceOptions) driver.Stmt {
+// wrapStmt wraps the statement prepared for query with the options of the
+// prepare call, prepareSpan being its span if it was traced.
+func wrapStmt(stmt driver.Stmt, query, fp string, prepareSpan Span, options TraceOptions, conn *zConn) driver.Stmt {
 	var (
 		_, hasExeCtx    = stmt.(driver.StmtExecContext)
 		_, hasQryCtx    = stmt.(driver.StmtQueryContext)
@@ -44,48 +44,56 @@ func wrapStmt(stmt driver.Stmt, query string, tracer *zipkin.Tracer, options Tra
 		n, hasNamValChk = stmt.(driver.NamedValueChecker)
 	)
 
-	s := zStmt{parent: stmt, query: query, tracer: tracer, options: options}
+	s := zStmt{
+		parent:      stmt,
+		query:       query,
+		fingerprint: fp,
+		conn:        conn,
+		prepareSpan: prepareSpan,
+		tracer:      conn.tracer,
+		options:     options,
+	}
 	switch {
 	case !hasExeCtx && !hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 		}{s}
 	case !hasExeCtx && hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtQueryContext
 		}{s, s}
 	case hasExeCtx && !hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 		}{s, s}
 	case hasExeCtx && hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.StmtQueryContext
 		}{s, s, s}
 	case !hasExeCtx && !hasQryCtx && hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.ColumnConverter
 		}{s, c}
 	case !hasExeCtx && hasQryCtx && hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtQueryContext
 			driver.ColumnConverter
 		}{s, s, c}
 	case hasExeCtx && !hasQryCtx && hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.ColumnConverter
 		}{s, s, c}
 	case hasExeCtx && hasQryCtx && hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.StmtQueryContext
 			driver.ColumnConverter
@@ -93,51 +101,51 @@ func wrapStmt(stmt driver.Stmt, query string, tracer *zipkin.Tracer, options Tra
 
 	case !hasExeCtx && !hasQryCtx && !hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.NamedValueChecker
 		}{s, n}
 	case !hasExeCtx && hasQryCtx && !hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtQueryContext
 			driver.NamedValueChecker
 		}{s, s, n}
 	case hasExeCtx && !hasQryCtx && !hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.NamedValueChecker
 		}{s, s, n}
 	case hasExeCtx && hasQryCtx && !hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.StmtQueryContext
 			driver.NamedValueChecker
 		}{s, s, s, n}
 	case !hasExeCtx && !hasQryCtx && hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.ColumnConverter
 			driver.NamedValueChecker
 		}{s, c, n}
 	case !hasExeCtx && hasQryCtx && hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtQueryContext
 			driver.ColumnConverter
 			driver.NamedValueChecker
 		}{s, s, c, n}
 	case hasExeCtx && !hasQryCtx && hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.ColumnConverter
 			driver.NamedValueChecker
 		}{s, s, c, n}
 	case hasExeCtx && hasQryCtx && hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.StmtQueryContext
 			driver.ColumnConverter
@@ -20,6 +20,7 @@ var (
 
 // WrapConnector allows wrapping a database driver.Connector which eliminates
 // the need to register zipkinsql as an available driver.Driver.
+// It panics if neither t nor the Tracer option is set.
 func WrapConnector(dc driver.Connector, t *zipkin.Tracer, options ...TraceOption) driver.Connector {
 	opts := TraceOptions{}
 	for _, o := range options {
@@ -29,7 +30,7 @@ func WrapConnector(dc driver.Connector, t *zipkin.Tracer, options ...TraceOption
 	return &zDriver{
 		parent:    dc.Driver(),
 		connector: dc,
-		tracer:    t,
+		tracer:    newTracer(t, opts),
 		options:   opts,
 	}
 }
@@ -38,18 +39,18 @@ func WrapConnector(dc driver.Connector, t *zipkin.Tracer, options ...TraceOption
 type zDriver struct {
 	parent    driver.Driver
 	connector driver.Connector
-	tracer    *zipkin.Tracer
+	tracer    Tracer
 	options   TraceOptions
 }
 
-func wrapDriver(d driver.Driver, t *zipkin.Tracer, o TraceOptions) driver.Driver {
+func wrapDriver(d driver.Driver, t Tracer, o TraceOptions) driver.Driver {
 	if _, ok := d.(driver.DriverContext); ok {
 		return zDriver{parent: d, tracer: t, options: o}
 	}
-	return struct{ driver.Driver }{zDriver{parent: d, tracer: t, options: o}}
+	return struct{ driverUnwrapper }{zDriver{parent: d, tracer: t, options: o}}
 }
 
-func wrapConn(parent driver.Conn, t *zipkin.Tracer, options TraceOptions) driver.Conn {
+func wrapConn(parent driver.Conn, t Tracer, options TraceOptions) driver.Conn {
 	var (
 		n, hasNameValueChecker = parent.(driver.NamedValueChecker)
 		s, hasSessionResetter  = parent.(driver.SessionResetter)
@@ -78,7 +79,9 @@ func wrapConn(parent driver.Conn, t *zipkin.Tracer, options TraceOptions) driver
 	panic("unreachable")
 }
 
-func wrapStmt(stmt driver.Stmt, query string, tracer *zipkin.Tracer, options TraceOptions) driver.Stmt {
+// wrapStmt wraps the statement prepared for query with the options of the
+// prepare call, prepareSpan being its span if it was traced.
+func wrapStmt(stmt driver.Stmt, query, fp string, prepareSpan Span, options TraceOptions, conn *zConn) driver.Stmt {
 	var (
 		_, hasExeCtx    = stmt.(driver.StmtExecContext)
 		_, hasQryCtx    = stmt.(driver.StmtQueryContext)
@@ -86,48 +89,56 @@ func wrapStmt(stmt driver.Stmt, query string, tracer *zipkin.Tracer, options Tra
 		n, hasNamValChk = stmt.(driver.NamedValueChecker)
 	)
 
-	s := zStmt{parent: stmt, query: query, tracer: tracer, options: options}
+	s := zStmt{
+		parent:      stmt,
+		query:       query,
+		fingerprint: fp,
+		conn:        conn,
+		prepareSpan: prepareSpan,
+		tracer:      conn.tracer,
+		options:     options,
+	}
 	switch {
 	case !hasExeCtx && !hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 		}{s}
 	case !hasExeCtx && hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtQueryContext
 		}{s, s}
 	case hasExeCtx && !hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 		}{s, s}
 	case hasExeCtx && hasQryCtx && !hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.StmtQueryContext
 		}{s, s, s}
 	case !hasExeCtx && !hasQryCtx && hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.ColumnConverter
 		}{s, c}
 	case !hasExeCtx && hasQryCtx && hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtQueryContext
 			driver.ColumnConverter
 		}{s, s, c}
 	case hasExeCtx && !hasQryCtx && hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.ColumnConverter
 		}{s, s, c}
 	case hasExeCtx && hasQryCtx && hasColConv && !hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.StmtQueryContext
 			driver.ColumnConverter
@@ -135,51 +146,51 @@ func wrapStmt(stmt driver.Stmt, query string, tracer *zipkin.Tracer, options Tra
 
 	case !hasExeCtx && !hasQryCtx && !hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.NamedValueChecker
 		}{s, n}
 	case !hasExeCtx && hasQryCtx && !hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtQueryContext
 			driver.NamedValueChecker
 		}{s, s, n}
 	case hasExeCtx && !hasQryCtx && !hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.NamedValueChecker
 		}{s, s, n}
 	case hasExeCtx && hasQryCtx && !hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.StmtQueryContext
 			driver.NamedValueChecker
 		}{s, s, s, n}
 	case !hasExeCtx && !hasQryCtx && hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.ColumnConverter
 			driver.NamedValueChecker
 		}{s, c, n}
 	case !hasExeCtx && hasQryCtx && hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtQueryContext
 			driver.ColumnConverter
 			driver.NamedValueChecker
 		}{s, s, c, n}
 	case hasExeCtx && !hasQryCtx && hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.ColumnConverter
 			driver.NamedValueChecker
 		}{s, s, c, n}
 	case hasExeCtx && hasQryCtx && hasColConv && hasNamValChk:
 		return struct {
-			driver.Stmt
+			stmtUnwrapper
 			driver.StmtExecContext
 			driver.StmtQueryContext
 			driver.ColumnConverter
@@ -199,11 +210,15 @@ func (d zDriver) OpenConnector(name string) (driver.Connector, error) {
 }
 
 func (d zDriver) Connect(ctx context.Context) (driver.Conn, error) {
-	c, err := d.connector.Connect(ctx)
+	var c driver.Conn
+	err := intercept(ctx, d.options.Interceptors, &Call{Op: OpConnect}, func(ctx context.Context) (err error) {
+		c, err = d.connector.Connect(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &zConn{parent: c, tracer: d.tracer, options: d.options}, nil
+	return wrapConn(c, d.tracer, d.options), nil
 }
 
 func (d zDriver) Driver() driver.Driver {
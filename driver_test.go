@@ -3,9 +3,12 @@ package zipkinsql
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"strings"
 	"testing"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	sqlite3 "github.com/mattn/go-sqlite3"
 	zipkin "github.com/openzipkin/zipkin-go"
 	"github.com/openzipkin/zipkin-go/model"
 	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
@@ -360,3 +363,428 @@ func TestTxWithRollbackSuccess(t *testing.T) {
 		recorder.Close()
 	}
 }
+
+func TestArgToTagValue(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	testCases := []struct {
+		arg       interface{}
+		opts      []TraceOption
+		value     string
+		truncated bool
+	}{
+		{nil, nil, "NULL", false},
+		{int64(42), nil, "42", false},
+		{1.5, nil, "1.5", false},
+		{0.1, nil, "0.1", false},
+		{true, nil, "true", false},
+		{"text", nil, "text", false},
+		{strings.Repeat("a", 300), nil, strings.Repeat("a", 256), true},
+		{strings.Repeat("a", 300), []TraceOption{WithMaxTagValueLength(10)}, strings.Repeat("a", 10), true},
+		{"añb", []TraceOption{WithMaxTagValueLength(2)}, "a", true},
+		{[]byte("bin"), nil, "bin", false},
+		{[]byte("bin"), []TraceOption{WithBinaryEncoding(BinaryHex)}, "62696e", false},
+		{[]byte("bin"), []TraceOption{WithBinaryEncoding(BinaryBase64)}, "Ymlu", false},
+		{[]byte("bin"), []TraceOption{WithBinaryEncoding(BinaryLength)}, "[3 bytes]", false},
+		{ts, nil, "2020-01-02T03:04:05.0000006Z", false},
+		{ts, []TraceOption{WithTimeFormat(time.RFC3339)}, "2020-01-02T03:04:05Z", false},
+	}
+
+	for _, c := range testCases {
+		o := TraceOptions{}
+		for _, opt := range c.opts {
+			opt(&o)
+		}
+		value, truncated := argToTagValue(c.arg, o)
+		if want, have := c.value, value; want != have {
+			t.Errorf("unexpected tag value for %v, want: %q, have: %q", c.arg, want, have)
+		}
+		if want, have := c.truncated, truncated; want != have {
+			t.Errorf("unexpected truncation for %v, want: %t, have: %t", c.arg, want, have)
+		}
+	}
+}
+
+func TestQueryTruncation(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(true), WithTagQuery(true), WithMaxQueryLength(8))
+	defer db.Close()
+	defer recorder.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1 WHERE 1 = 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+
+	spans := recorder.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := "SELECT 1", spans[0].Tags["sql.query"]; want != have {
+		t.Errorf("unexpected query tag, want: %q, have: %q", want, have)
+	}
+	if want, have := "true", spans[0].Tags["sql.truncated"]; want != have {
+		t.Errorf("unexpected truncation tag, want: %q, have: %q", want, have)
+	}
+}
+
+func TestParamsTagKeys(t *testing.T) {
+	testCases := []struct {
+		opts []TraceOption
+		keys []string
+	}{
+		{nil, []string{"sql.arg.1", "sql.arg.name"}},
+		{[]TraceOption{WithParamTagPrefix("db.param")}, []string{"db.param.1", "db.param.name"}},
+		{[]TraceOption{WithLegacyParamTagKeys(true)}, []string{"sql.arg.1", "name"}},
+	}
+
+	for _, c := range testCases {
+		opts := append([]TraceOption{WithAllowRootSpan(true), WithTagQuery(true), WithTagQueryParams(true)}, c.opts...)
+		db, _, recorder := createDB(t, opts...)
+
+		stmt, err := db.Prepare("SELECT ? WHERE 1 = 1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		rows, err := stmt.Query(1)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		rows.Close()
+		stmt.Close()
+
+		rows, err = db.QueryContext(context.Background(), "SELECT :name", sql.Named("name", 1))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		rows.Close()
+
+		spans := recorder.Flush()
+		if want, have := 3, len(spans); want != have {
+			t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+		}
+		for i, key := range c.keys {
+			if want, have := "1", spans[i+1].Tags[key]; want != have {
+				t.Errorf("unexpected %s tag, want: %q, have: %q", key, want, have)
+			}
+		}
+
+		db.Close()
+		recorder.Close()
+	}
+}
+
+func TestExecContextSpanPropagation(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	// record the span found in the context of the exec calls, the queries
+	// starting with SKIP falling back to a prepared statement
+	var driverSpans []zipkin.Span
+	d := &fakeDriver{exec: func(ctx context.Context, query string, prepared bool) (driver.Result, error) {
+		if !prepared && strings.HasPrefix(query, "SKIP") {
+			return nil, driver.ErrSkip
+		}
+		driverSpans = append(driverSpans, zipkin.SpanFromContext(ctx))
+		return driver.RowsAffected(1), nil
+	}}
+	db := sql.OpenDB(WrapConnector(d, tracer, WithAllowRootSpan(true)))
+	defer db.Close()
+
+	if _, err := db.ExecContext(context.Background(), "UPDATE foo SET bar = 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err := db.ExecContext(context.Background(), "SKIP UPDATE foo SET bar = 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans := reporter.Flush()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	for i, name := range []string{"sql/exec", "sql/prepare", "sql/exec"} {
+		if want, have := name, spans[i].Name; want != have {
+			t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+		}
+	}
+
+	if want, have := 2, len(driverSpans); want != have {
+		t.Fatalf("unexpected number of driver calls, want: %d, have: %d", want, have)
+	}
+	for i, span := range []int{0, 2} {
+		if driverSpans[i] == nil {
+			t.Fatalf("missing span in the driver context")
+		}
+		if want, have := spans[span].ID, driverSpans[i].Context().ID; want != have {
+			t.Errorf("unexpected span in the driver context, want: %s, have: %s", want, have)
+		}
+	}
+}
+
+func TestLegacyStmtSpanParent(t *testing.T) {
+	for _, link := range []bool{false, true} {
+		reporter := zipkinreporter.NewReporter()
+		tracer, _ := zipkin.NewTracer(reporter)
+
+		db, err := sql.Open("sqlite3", "file:test.db?cache=shared&mode=memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent, err := db.Driver().Open("file:test.db?cache=shared&mode=memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := WrapConn(parent, tracer, WithLinkPreparedStmtSpans(link)).(driver.ConnPrepareContext)
+
+		root, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+		stmt, err := conn.PrepareContext(ctx, "SELECT 1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		root.Finish()
+
+		// database/sql only calls the legacy methods for drivers not
+		// implementing driver.StmtExecContext
+		if _, err = stmt.Exec(nil); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		stmt.Close()
+
+		spans := reporter.Flush()
+		if want, have := 3, len(spans); want != have {
+			t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+		}
+		prepare, exec := spans[0], spans[2]
+		if want, have := "sql:exec", exec.Name; want != have {
+			t.Fatalf("unexpected span name, want: %s, have: %s", want, have)
+		}
+		if link {
+			if exec.ParentID != nil {
+				t.Errorf("unexpected parent for linked span: %s", exec.ParentID)
+			}
+			if want, have := prepare.TraceID.String()+":"+prepare.ID.String(), exec.Tags["link"]; want != have {
+				t.Errorf("unexpected link tag, want: %s, have: %s", want, have)
+			}
+		} else if exec.ParentID == nil || *exec.ParentID != prepare.ID {
+			t.Errorf("unexpected parent, want: %s, have: %v", prepare.ID, exec.ParentID)
+		}
+
+		parent.Close()
+		db.Close()
+		reporter.Close()
+	}
+}
+
+func TestLegacyStmtTracingDisabled(t *testing.T) {
+	for _, allowRootSpan := range []bool{false, true} {
+		reporter := zipkinreporter.NewReporter()
+		tracer, _ := zipkin.NewTracer(reporter)
+
+		db, err := sql.Open("sqlite3", "file:test.db?cache=shared&mode=memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent, err := db.Driver().Open("file:test.db?cache=shared&mode=memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := WrapConn(parent, tracer, WithAllowRootSpan(allowRootSpan)).(driver.ConnPrepareContext)
+
+		root, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+		stmt, err := conn.PrepareContext(WithContextTracingDisabled(ctx), "SELECT 1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		root.Finish()
+
+		if _, err = stmt.Exec(nil); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		stmt.Close()
+
+		// only the root span is recorded, the statement being prepared with
+		// tracing disabled
+		spans := reporter.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+		}
+		if want, have := "root", spans[0].Name; want != have {
+			t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+		}
+
+		parent.Close()
+		db.Close()
+		reporter.Close()
+	}
+}
+
+func TestLegacyBegin(t *testing.T) {
+	for _, allowRootSpan := range []bool{false, true} {
+		reporter := zipkinreporter.NewReporter()
+		tracer, _ := zipkin.NewTracer(reporter)
+
+		db, err := sql.Open("sqlite3", "file:test.db?cache=shared&mode=memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent, err := db.Driver().Open("file:test.db?cache=shared&mode=memory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := WrapConn(parent, tracer, WithAllowRootSpan(allowRootSpan))
+
+		// database/sql only calls Begin for drivers not implementing
+		// driver.ConnBeginTx
+		tx, err := conn.Begin()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if tx, err = conn.Begin(); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if err = tx.Rollback(); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		spans := reporter.Flush()
+		if !allowRootSpan {
+			if want, have := 0, len(spans); want != have {
+				t.Errorf("unexpected number of spans, want: %d, have: %d", want, have)
+			}
+		} else if want, have := 4, len(spans); want != have {
+			t.Errorf("unexpected number of spans, want: %d, have: %d", want, have)
+		} else {
+			for i, name := range []string{"sql/begin_transaction", "sql/commit", "sql/begin_transaction", "sql/rollback"} {
+				if want, have := name, spans[i].Name; want != have {
+					t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+				}
+			}
+		}
+
+		parent.Close()
+		db.Close()
+		reporter.Close()
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	db, tracer, recorder := createDB(t, WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	if _, ok := db.Driver().(interface{ Unwrap() driver.Driver }).Unwrap().(*sqlite3.SQLiteDriver); !ok {
+		t.Errorf("unexpected unwrapped driver type: %T", db.Driver())
+	}
+
+	parent, err := (&sqlite3.SQLiteDriver{}).Open("file:test.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+
+	c := WrapConn(parent, tracer, WithAllowRootSpan(true))
+	if want, have := parent, UnwrapConn(c); want != have {
+		t.Errorf("unexpected unwrapped conn, want: %v, have: %v", want, have)
+	}
+
+	stmt, err := c.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer stmt.Close()
+	if _, ok := stmt.(interface{ Unwrap() driver.Stmt }).Unwrap().(*sqlite3.SQLiteStmt); !ok {
+		t.Errorf("unexpected unwrapped stmt type: %T", stmt)
+	}
+
+	tx, err := c.(driver.ConnBeginTx).BeginTx(context.Background(), driver.TxOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer tx.Rollback()
+	if _, ok := tx.(interface{ Unwrap() driver.Tx }).Unwrap().(*sqlite3.SQLiteTx); !ok {
+		t.Errorf("unexpected unwrapped tx type: %T", tx)
+	}
+
+	// connections not wrapped by zipkinsql are returned as is
+	raw := &sqlite3.SQLiteConn{}
+	if want, have := driver.Conn(raw), UnwrapConn(raw); want != have {
+		t.Errorf("unexpected unwrapped conn, want: %v, have: %v", want, have)
+	}
+}
+
+func TestRegisterDriver(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	d := &sqlite3.SQLiteDriver{}
+	name, err := RegisterDriver("sqlite3-register-driver", d, tracer, WithAllowRootSpan(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "sqlite3-register-driver", name; want != have {
+		t.Errorf("unexpected driver name, want: %s, have: %s", want, have)
+	}
+
+	// registering the same driver again is a no-op
+	if name, err = RegisterDriver("sqlite3-register-driver", d, tracer); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "sqlite3-register-driver", name; want != have {
+		t.Errorf("unexpected driver name, want: %s, have: %s", want, have)
+	}
+
+	if _, err = RegisterDriver("sqlite3-register-driver", &sqlite3.SQLiteDriver{}, tracer); err == nil {
+		t.Error("expected an error registering another driver under the same name")
+	}
+	if _, err = RegisterDriver("sqlite3", d, tracer); err == nil {
+		t.Error("expected an error registering a driver under a name used by database/sql")
+	}
+	if _, err = RegisterDriver("sqlite3-nil", nil, tracer); err == nil {
+		t.Error("expected an error registering a nil driver")
+	}
+
+	db, err := sql.Open(name, "file:test.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 1, len(reporter.Flush()); want != have {
+		t.Errorf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+}
+
+func TestRegisterNoTracer(t *testing.T) {
+	if _, err := Register("sqlite3", nil); err != errNoTracer {
+		t.Errorf("unexpected error, want: %v, have: %v", errNoTracer, err)
+	}
+	if _, err := RegisterDriver("sqlite3-notracer", &sqlite3.SQLiteDriver{}, nil, WithAllTraceOptions()); err != errNoTracer {
+		t.Errorf("unexpected error, want: %v, have: %v", errNoTracer, err)
+	}
+}
+
+func TestAllTraceOptionsKeepBackends(t *testing.T) {
+	metrics := &metricsRecorder{}
+	db, _, recorder := createDB(t, WithMetrics(metrics), WithAllTraceOptions())
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx := WithContextOptions(context.Background(), WithOptions(TraceOptions{AllowRootSpan: true}))
+	if _, err := db.ExecContext(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if want, have := 1, len(recorder.Flush()); want != have {
+		t.Errorf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if len(metrics.latencies) == 0 {
+		t.Error("expected the metrics to be kept")
+	}
+}
@@ -0,0 +1,74 @@
+package zipkinsql
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// mysqlDSN matches the TCP data source names of go-sql-driver/mysql, e.g.
+// user:pass@tcp(localhost:3306)/db?parseTime=true.
+var mysqlDSN = regexp.MustCompile(`^(?:.*@)?tcp\(([^)]*)\)/([^?]*)`)
+
+// endpointFromDSN returns the remote endpoint of the database dsn connects
+// to, nil if it can't be found. URL (postgres://localhost:5432/db), MySQL
+// (tcp(localhost:3306)/db) and key/value (host=localhost port=5432 dbname=db)
+// data source names are supported. Host names are not resolved: the service
+// name is set to the database name or, if there is none, to the host.
+func endpointFromDSN(dsn string) *model.Endpoint {
+	var host, port, dbName string
+	if m := mysqlDSN.FindStringSubmatch(dsn); m != nil {
+		host, port, _ = net.SplitHostPort(m[1])
+		if host == "" {
+			host = m[1]
+		}
+		dbName = m[2]
+	} else if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return nil
+		}
+		host, port = u.Hostname(), u.Port()
+		dbName = strings.TrimPrefix(u.Path, "/")
+	} else {
+		for _, field := range strings.Fields(dsn) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value := strings.Trim(kv[1], "'")
+			switch kv[0] {
+			case "host":
+				host = value
+			case "port":
+				port = value
+			case "dbname":
+				dbName = value
+			}
+		}
+	}
+	if host == "" || strings.HasPrefix(host, "/") {
+		// no host or a unix socket
+		return nil
+	}
+
+	e := &model.Endpoint{ServiceName: dbName}
+	if e.ServiceName == "" {
+		e.ServiceName = host
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			e.IPv4 = ip4
+		} else {
+			e.IPv6 = ip
+		}
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+		e.Port = uint16(p)
+	}
+	return e
+}
@@ -0,0 +1,10 @@
+//go:build !go1.13
+// +build !go1.13
+
+package zipkinsql
+
+// errorIs reports whether err is target, errors not being wrapped before Go
+// 1.13.
+func errorIs(err, target error) bool {
+	return err == target
+}
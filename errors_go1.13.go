@@ -0,0 +1,11 @@
+//go:build go1.13
+// +build go1.13
+
+package zipkinsql
+
+import "errors"
+
+// errorIs reports whether any error in the chain of err matches target.
+func errorIs(err, target error) bool {
+	return errors.Is(err, target)
+}
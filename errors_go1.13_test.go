@@ -0,0 +1,25 @@
+//go:build go1.13
+// +build go1.13
+
+package zipkinsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+)
+
+func TestErrorClassWrapped(t *testing.T) {
+	testCases := map[error]string{
+		fmt.Errorf("querying users: %w", driver.ErrBadConn):        "bad_conn",
+		fmt.Errorf("querying users: %w", context.Canceled):         "canceled",
+		fmt.Errorf("querying users: %v", context.Canceled):         "*errors.errorString",
+		fmt.Errorf("querying users: %w", context.DeadlineExceeded): "deadline_exceeded",
+	}
+	for err, want := range testCases {
+		if have := errorClass(err); want != have {
+			t.Errorf("unexpected error class for %q, want: %s, have: %s", err, want, have)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Dialect identifies the SQL dialect spoken by a database.
+type Dialect int
+
+// Supported SQL dialects.
+const (
+	DialectUnknown Dialect = iota
+	DialectPostgres
+	DialectMySQL
+	DialectSQLite
+)
+
+const (
+	defaultExplainMaxLength = 1024
+	defaultExplainTimeout   = time.Second
+	defaultExplainInterval  = time.Minute
+)
+
+// writeKeywords are the keywords of the statements which change data or
+// schema, which are never explained.
+var writeKeywords = map[string]bool{
+	"INSERT":   true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"MERGE":    true,
+	"REPLACE":  true,
+	"INTO":     true,
+	"CREATE":   true,
+	"DROP":     true,
+	"ALTER":    true,
+	"TRUNCATE": true,
+}
+
+// ExplainOptions configures the capture of query plans for slow queries.
+// The plan is obtained by running an EXPLAIN statement, built from the slow
+// query and its arguments, on a separate connection pool and is recorded in
+// the sql.explain tag of the slow query span. Only single read-only statements
+// are explained, so nothing is run twice against the database.
+type ExplainOptions struct {
+	// DB is the connection pool used to run the EXPLAIN statements. It should
+	// not be wrapped by zipkinsql so explaining doesn't produce spans.
+	DB *sql.DB
+
+	// Dialect decides on the EXPLAIN statement syntax. Queries are not explained
+	// when set to DialectUnknown.
+	Dialect Dialect
+
+	// Threshold is the duration above which a query is considered slow.
+	Threshold time.Duration
+
+	// Interval is the minimum time between two EXPLAIN statements, which limits
+	// the load put on the database. Defaults to one minute when not set, a
+	// negative Interval explaining every slow query.
+	Interval time.Duration
+
+	// Timeout bounds the time an EXPLAIN statement can take. Defaults to one
+	// second when not set.
+	Timeout time.Duration
+
+	// MaxLength is the length the plan is truncated to. Defaults to 1024 bytes
+	// when not set.
+	MaxLength int
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// explainStatement returns the EXPLAIN statement for query in the dialect.
+func explainStatement(dialect Dialect, query string) (string, bool) {
+	switch dialect {
+	case DialectPostgres, DialectMySQL:
+		return "EXPLAIN " + query, true
+	case DialectSQLite:
+		return "EXPLAIN QUERY PLAN " + query, true
+	default:
+		return "", false
+	}
+}
+
+// readOnlyStatement tells if query is a single statement reading data, which
+// can be explained without side effects.
+func readOnlyStatement(query string) bool {
+	shape := strings.TrimRight(normalizeQuery(query), "; ")
+	if strings.Contains(shape, ";") {
+		return false
+	}
+
+	words := strings.FieldsFunc(shape, func(r rune) bool {
+		return r >= utf8.RuneSelf || !isIdentByte(byte(r))
+	})
+	if len(words) == 0 {
+		return false
+	}
+	switch strings.ToUpper(words[0]) {
+	case "SELECT", "WITH":
+	default:
+		return false
+	}
+	for _, word := range words[1:] {
+		if writeKeywords[strings.ToUpper(word)] {
+			return false
+		}
+	}
+	return true
+}
+
+// allow reports whether query, which took d, can be explained now, reserving
+// the slot for it if so.
+func (e *ExplainOptions) allow(query string, d time.Duration) bool {
+	if e == nil || e.DB == nil || d < e.Threshold || !readOnlyStatement(query) {
+		return false
+	}
+
+	interval := e.Interval
+	if interval == 0 {
+		interval = defaultExplainInterval
+	}
+
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.last.IsZero() && now.Sub(e.last) < interval {
+		return false
+	}
+	e.last = now
+	return true
+}
+
+// explain runs the EXPLAIN statement for query and returns the plan and
+// whether it was truncated.
+func (e *ExplainOptions) explain(query string, args []driver.NamedValue) (string, bool, error) {
+	stmt, ok := explainStatement(e.Dialect, query)
+	if !ok {
+		return "", false, fmt.Errorf("unsupported dialect %d", e.Dialect)
+	}
+
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultExplainTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := e.DB.QueryContext(ctx, stmt, namedValuesToArgs(args)...)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", false, err
+	}
+
+	var b strings.Builder
+	if len(cols) > 1 {
+		b.WriteString(strings.Join(cols, "\t"))
+	}
+
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return "", false, err
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		for i, v := range values {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			b.WriteString(v.String)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return "", false, err
+	}
+
+	maxLength := e.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultExplainMaxLength
+	}
+	plan, truncated := truncate(b.String(), maxLength)
+	return plan, truncated, nil
+}
+
+// finishWithExplain finishes the span of a query started at startTime. If the
+// query was slow, it is explained in the background and the span is finished
+// once the plan is recorded so the caller is not delayed.
+func finishWithExplain(span Span, startTime time.Time, options TraceOptions, query string, args []driver.NamedValue) {
+	d := time.Since(startTime)
+	if !options.Explain.allow(query, d) {
+		span.Finish()
+		return
+	}
+
+	// arguments may be reused by the caller once the query returned
+	args = append([]driver.NamedValue(nil), args...)
+	for i, arg := range args {
+		if b, ok := arg.Value.([]byte); ok {
+			args[i].Value = append([]byte(nil), b...)
+		}
+	}
+
+	go func() {
+		plan, truncated, err := options.Explain.explain(query, args)
+		if err != nil {
+			span.Tag("sql.explain.error", err.Error())
+		} else {
+			span.Tag("sql.explain", plan)
+			if truncated {
+				setSpanTruncated(span)
+			}
+		}
+		span.FinishedWithDuration(d)
+	}()
+}
+
+func namedValuesToArgs(named []driver.NamedValue) []interface{} {
+	args := make([]interface{}, len(named))
+	for i, arg := range named {
+		if arg.Name != "" {
+			args[i] = sql.Named(arg.Name, arg.Value)
+		} else {
+			args[i] = arg.Value
+		}
+	}
+	return args
+}
@@ -0,0 +1,170 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+func TestExplainSlowQuery(t *testing.T) {
+	explainDB, err := sql.Open("sqlite3", "file:test.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer explainDB.Close()
+
+	explain := &ExplainOptions{
+		DB:       explainDB,
+		Dialect:  DialectSQLite,
+		Interval: time.Hour,
+	}
+	db, _, recorder := createDB(t, WithAllowRootSpan(true), WithExplain(explain))
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx := context.Background()
+	if _, err = db.ExecContext(ctx, "create table if not exists explained (id integer not null primary key, name text)"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	recorder.Flush()
+
+	for i := 0; i < 2; i++ {
+		rows, err := db.QueryContext(ctx, "SELECT name FROM explained WHERE id = ?", 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		rows.Close()
+	}
+
+	// the first query is explained in the background
+	var spans []model.SpanModel
+	for deadline := time.Now().Add(5 * time.Second); len(spans) < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		spans = append(spans, recorder.Flush()...)
+	}
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+
+	var explained int
+	for _, span := range spans {
+		if errMsg, ok := span.Tags["sql.explain.error"]; ok {
+			t.Fatalf("unexpected explain error: %s", errMsg)
+		}
+		if plan, ok := span.Tags["sql.explain"]; ok {
+			explained++
+			if !strings.Contains(plan, "explained") {
+				t.Errorf("unexpected plan: %s", plan)
+			}
+		}
+	}
+	if want, have := 1, explained; want != have {
+		t.Errorf("unexpected number of explained queries, want: %d, have: %d", want, have)
+	}
+}
+
+func TestExplainStatement(t *testing.T) {
+	testCases := map[Dialect]string{
+		DialectPostgres: "EXPLAIN SELECT 1",
+		DialectMySQL:    "EXPLAIN SELECT 1",
+		DialectSQLite:   "EXPLAIN QUERY PLAN SELECT 1",
+		DialectUnknown:  "",
+	}
+	for dialect, want := range testCases {
+		have, ok := explainStatement(dialect, "SELECT 1")
+		if want != have || ok != (want != "") {
+			t.Errorf("unexpected statement for dialect %d, want: %q, have: %q", dialect, want, have)
+		}
+	}
+}
+
+func TestExplainReadOnlyStatement(t *testing.T) {
+	testCases := map[string]bool{
+		"SELECT name FROM users WHERE id = ?":                        true,
+		"  select name from users; ":                                 true,
+		"WITH u AS (SELECT id FROM users) SELECT * FROM u":           true,
+		"SELECT ';' FROM users -- ; DELETE FROM users":               true,
+		"SELECT 1; DELETE FROM users":                                false,
+		"UPDATE users SET name = ? WHERE id = ?; DELETE FROM users":  false,
+		"DELETE FROM users WHERE id = ?":                             false,
+		"INSERT INTO users (name) SELECT name FROM accounts":         false,
+		"WITH d AS (DELETE FROM users RETURNING id) SELECT * FROM d": false,
+		"SELECT * INTO archive FROM users":                           false,
+		"SELECT * FROM users WHERE id = ? FOR UPDATE":                false,
+		"": false,
+	}
+	for query, want := range testCases {
+		if have := readOnlyStatement(query); want != have {
+			t.Errorf("unexpected read-only statement %q, want: %t, have: %t", query, want, have)
+		}
+	}
+}
+
+func TestExplainInterval(t *testing.T) {
+	explainDB, err := sql.Open("sqlite3", "file:test.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer explainDB.Close()
+
+	testCases := []struct {
+		interval time.Duration
+		allowed  int
+	}{
+		// the default interval limits the EXPLAIN statements
+		{0, 1},
+		{time.Hour, 1},
+		{-1, 3},
+	}
+	for _, c := range testCases {
+		explain := &ExplainOptions{DB: explainDB, Dialect: DialectSQLite, Interval: c.interval}
+
+		var allowed int
+		for i := 0; i < 3; i++ {
+			if explain.allow("SELECT 1", time.Second) {
+				allowed++
+			}
+		}
+		if want, have := c.allowed, allowed; want != have {
+			t.Errorf("unexpected number of explained queries for interval %s, want: %d, have: %d", c.interval, want, have)
+		}
+	}
+
+	explain := &ExplainOptions{DB: explainDB, Dialect: DialectSQLite, Interval: -1}
+	if explain.allow("DELETE FROM users", time.Second) {
+		t.Error("unexpected explain of a statement writing data")
+	}
+}
+
+func TestExplainTruncate(t *testing.T) {
+	explainDB, err := sql.Open("sqlite3", "file:truncate.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer explainDB.Close()
+
+	if _, err = explainDB.Exec("create table if not exists ééé (id integer)"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	explain := &ExplainOptions{
+		DB:        explainDB,
+		Dialect:   DialectSQLite,
+		MaxLength: 8,
+	}
+	plan, truncated, err := explain.explain("SELECT id FROM ééé", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !truncated {
+		t.Errorf("expected the plan to be truncated: %q", plan)
+	}
+	if len(plan) > explain.MaxLength || !utf8.ValidString(plan) {
+		t.Errorf("unexpected plan: %q", plan)
+	}
+}
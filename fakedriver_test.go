@@ -0,0 +1,78 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// fakeDriver is a driver and connector whose exec calls, made on the
+// connections or on prepared statements, are answered by exec. They succeed
+// when exec is nil.
+type fakeDriver struct {
+	exec func(ctx context.Context, query string, prepared bool) (driver.Result, error)
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{d}, nil
+}
+
+func (d *fakeDriver) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{d}, nil
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d *fakeDriver) execContext(ctx context.Context, query string, prepared bool) (driver.Result, error) {
+	if d.exec == nil {
+		return driver.RowsAffected(1), nil
+	}
+	return d.exec(ctx, query, prepared)
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{d: c.d, query: query}, nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	return c.d.execContext(ctx, query, false)
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return s.d.execContext(context.Background(), s.query, true)
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s fakeStmt) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	return s.d.execContext(ctx, s.query, true)
+}
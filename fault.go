@@ -0,0 +1,83 @@
+package zipkinsql
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Fault describes a failure injected, for chaos testing, in the exec, query,
+// prepare and begin transaction calls made with a context. Faults are set
+// with WithFaults, either on the driver or, through WithContextOptions, for
+// the calls made with a given context. The spans of the affected calls are
+// tagged with sql.fault.injected.
+type Fault struct {
+	// Query, if set, restricts the fault to the queries it matches. Begin
+	// transaction calls, having no query, are only affected by faults without
+	// a Query.
+	Query *regexp.Regexp
+
+	// Probability is the probability, between 0 and 1, for a matching call to
+	// be affected.
+	Probability float64
+
+	// Latency is added to the affected calls. They fail with the context error
+	// if the context is done before.
+	Latency time.Duration
+
+	// Err, if set, is returned by the affected calls instead of calling the
+	// driver, e.g. driver.ErrBadConn to exercise the database/sql retries.
+	Err error
+}
+
+// pickFault returns the first fault matching query and drawn for the call, if
+// any.
+func pickFault(faults []Fault, query string) *Fault {
+	for i := range faults {
+		f := &faults[i]
+		if f.Query != nil && (query == "" || !f.Query.MatchString(query)) {
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() < f.Probability {
+			return f
+		}
+	}
+	return nil
+}
+
+// inject applies the fault to a call made with ctx. A nil fault does nothing.
+func (f *Fault) inject(ctx context.Context) error {
+	if f == nil {
+		return nil
+	}
+
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return f.Err
+}
+
+// setSpanFault tags the span with the fault injected in the call, if any,
+// e.g. "latency=100ms error=driver: bad connection".
+func setSpanFault(span Span, f *Fault) {
+	if f == nil {
+		return
+	}
+
+	var desc []string
+	if f.Latency > 0 {
+		desc = append(desc, "latency="+f.Latency.String())
+	}
+	if f.Err != nil {
+		desc = append(desc, "error="+f.Err.Error())
+	}
+	span.Tag("sql.fault.injected", strings.Join(desc, " "))
+}
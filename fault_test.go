@@ -0,0 +1,74 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFaultInjection(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(true), WithFaults(Fault{
+		Query:       regexp.MustCompile(`^SELECT 2`),
+		Probability: 1,
+		Latency:     time.Millisecond,
+		Err:         driver.ErrBadConn,
+	}))
+	defer db.Close()
+	defer recorder.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+
+	if _, err = db.QueryContext(context.Background(), "SELECT 2"); err != driver.ErrBadConn {
+		t.Fatalf("unexpected error, want: %v, have: %v", driver.ErrBadConn, err)
+	}
+
+	spans := recorder.Flush()
+	if _, ok := spans[0].Tags["sql.fault.injected"]; ok {
+		t.Errorf("unexpected sql.fault.injected tag on the unmatched query")
+	}
+	// database/sql retries calls failing with driver.ErrBadConn
+	for _, span := range spans[1:] {
+		if want, have := "latency=1ms error=driver: bad connection", span.Tags["sql.fault.injected"]; want != have {
+			t.Errorf("unexpected sql.fault.injected tag, want: %q, have: %q", want, have)
+		}
+	}
+}
+
+func TestContextFaultInjection(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(false))
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ctx = WithContextOptions(ctx, WithFaults(Fault{Probability: 1, Latency: time.Second}))
+
+	if _, err := db.ExecContext(ctx, "SELECT 1"); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error, want: %v, have: %v", context.DeadlineExceeded, err)
+	}
+	if want, have := 0, len(recorder.Flush()); want != have {
+		t.Errorf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+}
+
+func TestPickFault(t *testing.T) {
+	faults := []Fault{
+		{Query: regexp.MustCompile(`users`), Probability: 1, Err: driver.ErrBadConn},
+		{Probability: 0, Err: driver.ErrSkip},
+	}
+	if f := pickFault(faults, "SELECT * FROM users"); f != &faults[0] {
+		t.Errorf("unexpected fault, want: %v, have: %v", &faults[0], f)
+	}
+	if f := pickFault(faults, "SELECT * FROM orders"); f != nil {
+		t.Errorf("unexpected fault: %v", f)
+	}
+	if f := pickFault(faults, ""); f != nil {
+		t.Errorf("unexpected fault for begin transaction: %v", f)
+	}
+}
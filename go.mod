@@ -3,8 +3,7 @@ module github.com/openzipkin-contrib/zipkin-go-sql
 go 1.13
 
 require (
-	github.com/go-sql-driver/mysql v1.5.0 // indirect
-	github.com/jmoiron/sqlx v1.2.0
+	github.com/jmoiron/sqlx v1.3.5
 	github.com/lib/pq v1.3.0
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible
 	github.com/openzipkin/zipkin-go v0.2.2
@@ -0,0 +1,401 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// Op identifies a driver operation.
+type Op string
+
+// Intercepted driver operations.
+const (
+	OpConnect   Op = "connect"
+	OpPing      Op = "ping"
+	OpPrepare   Op = "prepare"
+	OpExec      Op = "exec"
+	OpQuery     Op = "query"
+	OpRowsNext  Op = "rows_next"
+	OpRowsClose Op = "rows_close"
+	OpBegin     Op = "begin"
+	OpCommit    Op = "commit"
+	OpRollback  Op = "rollback"
+)
+
+// Call describes an intercepted driver operation.
+type Call struct {
+	// Op is the operation.
+	Op Op
+
+	// Query is the query of the prepare, exec, query and rows operations.
+	// Before can rewrite it for the prepare operations and the exec and query
+	// operations not using a prepared statement, the rewritten query being
+	// sent to the driver.
+	Query string
+
+	// Args are the arguments of the exec and query operations. Before can
+	// rewrite them.
+	Args []driver.NamedValue
+
+	// Err is the error the operation failed with, set before calling After.
+	// It is io.EOF for the rows next operation once the rows are exhausted.
+	Err error
+
+	// Result is the result of a successful exec, set before calling After.
+	Result driver.Result
+
+	// Dest holds the values of the row read by a successful rows next, set
+	// before calling After.
+	Dest []driver.Value
+}
+
+// Interceptor hooks the driver operations, with or without context and
+// whether they are traced or not. Interceptors are set with WithInterceptors
+// and run in order, the After hooks in reverse order.
+//
+// The zipkinsql tracing is the first built-in interceptor of every traced
+// operation, followed by the fault injection, see WithFaults, and the
+// interceptors set with WithInterceptors. The spans thus start before the
+// Before hooks of the other interceptors and end after their After hooks, so
+// their latency and errors are part of the spans, and record the query and
+// arguments given by database/sql, not the ones rewritten by the other
+// interceptors.
+type Interceptor interface {
+	// Before is called before the operation. The returned context is passed to
+	// the next interceptors, the driver and the After hook. Returning an error
+	// aborts the operation, only the After hooks of the interceptors which ran
+	// are called.
+	Before(ctx context.Context, call *Call) (context.Context, error)
+
+	// After is called once the operation returned.
+	After(ctx context.Context, call *Call)
+}
+
+// InterceptorFuncs is an Interceptor made of optional functions.
+type InterceptorFuncs struct {
+	BeforeFunc func(ctx context.Context, call *Call) (context.Context, error)
+	AfterFunc  func(ctx context.Context, call *Call)
+}
+
+// Before calls BeforeFunc, if set.
+func (f InterceptorFuncs) Before(ctx context.Context, call *Call) (context.Context, error) {
+	if f.BeforeFunc == nil {
+		return ctx, nil
+	}
+	return f.BeforeFunc(ctx, call)
+}
+
+// After calls AfterFunc, if set.
+func (f InterceptorFuncs) After(ctx context.Context, call *Call) {
+	if f.AfterFunc != nil {
+		f.AfterFunc(ctx, call)
+	}
+}
+
+// spanInterceptor is the built-in Interceptor tracing an operation. A new one
+// is set up for every traced call.
+type spanInterceptor struct {
+	tracer  Tracer
+	options TraceOptions
+	// name is the name of the span, before the SpanNameFormatter applies.
+	name        string
+	fingerprint string
+	fault       *Fault
+	// spanOptions holds the parent or the links of the span, if any.
+	spanOptions SpanOptions
+	// noContext is set for the calls made without a context of their own,
+	// which don't watch the context nor track the retries, the N+1 queries
+	// and the slow queries.
+	noContext bool
+	// stmt is set for the calls executing a prepared statement.
+	stmt bool
+
+	span      Span
+	ctx       context.Context
+	callCtx   context.Context
+	startTime time.Time
+	query     string
+	args      []driver.NamedValue
+	watch     *ctxWatch
+}
+
+// Before starts the span, recording the query and the arguments as given by
+// database/sql.
+func (i *spanInterceptor) Before(ctx context.Context, call *Call) (context.Context, error) {
+	i.callCtx, i.startTime = ctx, time.Now()
+	i.query, i.args = call.Query, call.Args
+
+	spanOptions := i.spanOptions
+	spanOptions.StartTime = i.startTime
+	spanOptions.RemoteEndpoint = remoteEndpoint(i.options.RemoteEndpoint)
+	i.span, i.ctx = i.tracer.StartSpan(ctx, spanName(i.name, i.options), spanOptions)
+
+	if i.fingerprint != "" {
+		i.span.Tag("sql.query.fingerprint", i.fingerprint)
+	}
+	if i.options.TagQuery && call.Op != OpBegin && call.Op != OpCommit && call.Op != OpRollback {
+		setSpanQuery(i.span, i.query, i.options)
+		if i.options.TagQueryParams {
+			if i.noContext {
+				addParamsTags(i.span, namedValuesToValues(i.args), i.options)
+			} else {
+				addNamedParamsTags(i.span, i.args, i.options)
+			}
+		}
+	}
+	setSpanDefaultTags(i.span, i.options.DefaultTags)
+	setSpanCaller(i.span, i.options)
+	setSpanFault(i.span, i.fault)
+	if !i.noContext {
+		setSpanRetryAttempt(i.span, badConnRetries.attempt(ctx, i.name, i.query))
+		i.watch = watchContext(ctx, i.options.TagCancelLatency)
+	}
+
+	return i.ctx, nil
+}
+
+// After finishes the span, once the query is explained if it was slow.
+func (i *spanInterceptor) After(_ context.Context, call *Call) {
+	err := call.Err
+	if i.watch != nil {
+		i.watch.stop()
+		badConnRetries.done(i.callCtx, i.name, i.query, err)
+	}
+	if err == driver.ErrSkip {
+		// database/sql falls back to a prepared statement having spans of
+		// its own, so the span is dropped by not finishing it
+		return
+	}
+
+	if !i.noContext && (call.Op == OpExec || call.Op == OpQuery) {
+		nPlusOneQueries.observe(i.callCtx, i.tracer, i.query, i.options)
+	}
+	if i.watch != nil {
+		i.watch.tag(i.span, err)
+	}
+	setSpanError(i.span, err)
+	if call.Op == OpExec && err == nil && i.stmt && i.options.TagAffectedRows {
+		if affectedRows, aRErr := call.Result.RowsAffected(); aRErr != nil {
+			i.span.Tag("sql.affected_rows", fmt.Sprintf("%d", affectedRows))
+		}
+	}
+	logCall(i.callCtx, i.span, i.options, i.name, i.query, i.args, i.startTime, call.Result, err)
+
+	if call.Op == OpExec && err == nil {
+		call.Result = zResult{parent: call.Result, tracer: i.tracer, ctx: i.ctx, options: i.options}
+	}
+	if call.Op == OpQuery && err == nil && !i.noContext {
+		finishWithExplain(i.span, i.startTime, i.options, i.query, i.args)
+		return
+	}
+	i.span.Finish()
+}
+
+// spanOrNil returns the span of the call traced by i, nil if i is nil.
+func (i *spanInterceptor) spanOrNil() Span {
+	if i == nil {
+		return nil
+	}
+	return i.span
+}
+
+// faultInterceptor is the built-in Interceptor injecting a fault.
+type faultInterceptor struct {
+	fault *Fault
+}
+
+func (i faultInterceptor) Before(ctx context.Context, _ *Call) (context.Context, error) {
+	return ctx, i.fault.inject(ctx)
+}
+
+func (faultInterceptor) After(context.Context, *Call) {}
+
+// callInterceptors returns the interceptors of a call: tracing first, if the
+// call is traced, then the injection of fault, if any, and the interceptors set
+// with WithInterceptors.
+func callInterceptors(tracing *spanInterceptor, fault *Fault, interceptors []Interceptor) []Interceptor {
+	if tracing == nil && fault == nil {
+		return interceptors
+	}
+
+	chain := make([]Interceptor, 0, len(interceptors)+2)
+	if tracing != nil {
+		chain = append(chain, tracing)
+	}
+	if fault != nil {
+		chain = append(chain, faultInterceptor{fault: fault})
+	}
+	return append(chain, interceptors...)
+}
+
+// rowsInterceptors returns the interceptors of the rows returned by a query,
+// leaving out the built-in ones which only apply to the query.
+func rowsInterceptors(interceptors []Interceptor) []Interceptor {
+	for n, i := range interceptors {
+		switch i.(type) {
+		case *spanInterceptor, faultInterceptor:
+		default:
+			return interceptors[n:]
+		}
+	}
+	return nil
+}
+
+// intercept runs the operation described by call through the interceptors.
+func intercept(ctx context.Context, interceptors []Interceptor, call *Call, op func(ctx context.Context) error) error {
+	if len(interceptors) == 0 {
+		return op(ctx)
+	}
+
+	var (
+		ctxs = make([]context.Context, 0, len(interceptors))
+		err  error
+	)
+	for _, i := range interceptors {
+		var next context.Context
+		if next, err = i.Before(ctx, call); err != nil {
+			break
+		}
+		ctx = next
+		ctxs = append(ctxs, ctx)
+	}
+	if err == nil {
+		err = op(ctx)
+	}
+
+	call.Err = err
+	for n := len(ctxs) - 1; n >= 0; n-- {
+		interceptors[n].After(ctxs[n], call)
+	}
+	return err
+}
+
+func interceptExec(ctx context.Context, interceptors []Interceptor, query string, args []driver.NamedValue, execFn func(context.Context, string, []driver.NamedValue) (driver.Result, error)) (driver.Result, error) {
+	call := &Call{Op: OpExec, Query: query, Args: args}
+	err := intercept(ctx, interceptors, call, func(ctx context.Context) (err error) {
+		call.Result, err = execFn(ctx, call.Query, call.Args)
+		return err
+	})
+	return call.Result, err
+}
+
+func interceptQuery(ctx context.Context, interceptors []Interceptor, query string, args []driver.NamedValue, queryFn func(context.Context, string, []driver.NamedValue) (driver.Rows, error)) (driver.Rows, error) {
+	var (
+		call = &Call{Op: OpQuery, Query: query, Args: args}
+		rows driver.Rows
+	)
+	err := intercept(ctx, interceptors, call, func(ctx context.Context) (err error) {
+		rows, err = queryFn(ctx, call.Query, call.Args)
+		if ri := rowsInterceptors(interceptors); err == nil && len(ri) > 0 {
+			rows = zRows{parent: rows, ctx: ctx, query: call.Query, interceptors: ri}
+		}
+		return err
+	})
+	return rows, err
+}
+
+func interceptPrepare(ctx context.Context, interceptors []Interceptor, query string, prepareFn func(context.Context, string) (driver.Stmt, error)) (driver.Stmt, error) {
+	var (
+		call = &Call{Op: OpPrepare, Query: query}
+		stmt driver.Stmt
+	)
+	err := intercept(ctx, interceptors, call, func(ctx context.Context) (err error) {
+		stmt, err = prepareFn(ctx, call.Query)
+		return err
+	})
+	return stmt, err
+}
+
+// zRows implements driver.Rows, running Next and Close through the
+// interceptors. It forwards the optional interfaces database/sql relies on,
+// falling back to the database/sql defaults.
+type zRows struct {
+	parent       driver.Rows
+	ctx          context.Context
+	query        string
+	interceptors []Interceptor
+}
+
+// Compile time assertions
+var (
+	_ driver.RowsNextResultSet              = zRows{}
+	_ driver.RowsColumnTypeDatabaseTypeName = zRows{}
+	_ driver.RowsColumnTypeLength           = zRows{}
+	_ driver.RowsColumnTypeNullable         = zRows{}
+	_ driver.RowsColumnTypePrecisionScale   = zRows{}
+	_ driver.RowsColumnTypeScanType         = zRows{}
+)
+
+func (r zRows) Columns() []string {
+	return r.parent.Columns()
+}
+
+func (r zRows) Close() error {
+	return intercept(r.ctx, r.interceptors, &Call{Op: OpRowsClose, Query: r.query}, func(context.Context) error {
+		return r.parent.Close()
+	})
+}
+
+func (r zRows) Next(dest []driver.Value) error {
+	call := &Call{Op: OpRowsNext, Query: r.query}
+	return intercept(r.ctx, r.interceptors, call, func(context.Context) error {
+		err := r.parent.Next(dest)
+		if err == nil {
+			call.Dest = dest
+		}
+		return err
+	})
+}
+
+func (r zRows) HasNextResultSet() bool {
+	if rs, ok := r.parent.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (r zRows) NextResultSet() error {
+	if rs, ok := r.parent.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r zRows) ColumnTypeDatabaseTypeName(index int) string {
+	if ct, ok := r.parent.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return ct.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r zRows) ColumnTypeLength(index int) (int64, bool) {
+	if ct, ok := r.parent.(driver.RowsColumnTypeLength); ok {
+		return ct.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r zRows) ColumnTypeNullable(index int) (bool, bool) {
+	if ct, ok := r.parent.(driver.RowsColumnTypeNullable); ok {
+		return ct.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r zRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if ct, ok := r.parent.(driver.RowsColumnTypePrecisionScale); ok {
+		return ct.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func (r zRows) ColumnTypeScanType(index int) reflect.Type {
+	if ct, ok := r.parent.(driver.RowsColumnTypeScanType); ok {
+		return ct.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
@@ -0,0 +1,214 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+// opRecorder records the intercepted operations.
+type opRecorder struct {
+	ops []string
+}
+
+func (r *opRecorder) Before(ctx context.Context, call *Call) (context.Context, error) {
+	r.ops = append(r.ops, "before "+string(call.Op))
+	return ctx, nil
+}
+
+func (r *opRecorder) After(_ context.Context, call *Call) {
+	op := "after " + string(call.Op)
+	if call.Err != nil && call.Err != io.EOF {
+		op += " " + call.Err.Error()
+	}
+	r.ops = append(r.ops, op)
+}
+
+func TestInterceptors(t *testing.T) {
+	recorder := &opRecorder{}
+	db, _, spans := createDB(t, WithAllowRootSpan(true), WithTagQuery(true), WithInterceptors(
+		recorder,
+		InterceptorFuncs{BeforeFunc: func(ctx context.Context, call *Call) (context.Context, error) {
+			call.Query = strings.Replace(call.Query, "/* rewrite */ 1", "2", 1)
+			return ctx, nil
+		}},
+	))
+	defer db.Close()
+	defer spans.Close()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	var n int
+	if err = tx.QueryRowContext(context.Background(), "SELECT /* rewrite */ 1").Scan(&n); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if want, have := 2, n; want != have {
+		t.Errorf("unexpected rewritten query result, want: %d, have: %d", want, have)
+	}
+
+	want := []string{
+		"before connect", "after connect",
+		"before begin", "after begin",
+		"before query", "after query",
+		"before rows_next", "after rows_next",
+		"before rows_close", "after rows_close",
+		"before commit", "after commit",
+	}
+	if have := recorder.ops; strings.Join(want, ",") != strings.Join(have, ",") {
+		t.Errorf("unexpected operations\nwant: %v\nhave: %v", want, have)
+	}
+
+	// the interceptors run inside the tracing so the span holds the original
+	// query
+	for _, span := range spans.Flush() {
+		if span.Name == "sql/query" {
+			if want, have := "SELECT /* rewrite */ 1", span.Tags["sql.query"]; want != have {
+				t.Errorf("unexpected sql.query tag, want: %q, have: %q", want, have)
+			}
+		}
+	}
+}
+
+func TestInterceptorAbort(t *testing.T) {
+	errDenied := errors.New("denied")
+	recorder := &opRecorder{}
+	db, _, spans := createDB(t, WithAllowRootSpan(true), WithInterceptors(
+		recorder,
+		InterceptorFuncs{BeforeFunc: func(ctx context.Context, call *Call) (context.Context, error) {
+			if call.Op == OpExec {
+				return ctx, errDenied
+			}
+			return ctx, nil
+		}},
+	))
+	defer db.Close()
+	defer spans.Close()
+
+	if _, err := db.ExecContext(context.Background(), "DELETE FROM users"); err != errDenied {
+		t.Fatalf("unexpected error, want: %v, have: %v", errDenied, err)
+	}
+
+	if want, have := "after exec denied", recorder.ops[len(recorder.ops)-1]; want != have {
+		t.Errorf("unexpected last operation, want: %q, have: %q", want, have)
+	}
+
+	s := spans.Flush()
+	if want, have := 1, len(s); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := "denied", s[0].Tags["error"]; want != have {
+		t.Errorf("unexpected error tag, want: %q, have: %q", want, have)
+	}
+}
+
+func TestInterceptorsWithoutContext(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	parent, err := (&sqlite3.SQLiteDriver{}).Open("file:test.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+
+	recorder := &opRecorder{}
+	c := WrapConn(parent, tracer, WithInterceptors(
+		recorder,
+		InterceptorFuncs{BeforeFunc: func(ctx context.Context, call *Call) (context.Context, error) {
+			call.Query = strings.Replace(call.Query, "/* rewrite */ 1", "2", 1)
+			return ctx, nil
+		}},
+	))
+
+	if _, err = c.(driver.Execer).Exec("SELECT 1", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	stmt, err := c.Prepare("SELECT /* rewrite */ 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	dest := make([]driver.Value, 1)
+	if err = rows.Next(dest); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+	if want, have := int64(2), dest[0]; want != have {
+		t.Errorf("unexpected rewritten query result, want: %d, have: %v", want, have)
+	}
+
+	want := []string{
+		"before exec", "after exec",
+		"before prepare", "after prepare",
+		"before query", "after query",
+		"before rows_next", "after rows_next",
+		"before rows_close", "after rows_close",
+	}
+	if have := recorder.ops; strings.Join(want, ",") != strings.Join(have, ",") {
+		t.Errorf("unexpected operations\nwant: %v\nhave: %v", want, have)
+	}
+}
+
+func TestInterceptorsInsideSpan(t *testing.T) {
+	var spanIDs []string
+	db, tracer, spans := createDB(t, WithAllowRootSpan(true), WithInterceptors(
+		InterceptorFuncs{BeforeFunc: func(ctx context.Context, call *Call) (context.Context, error) {
+			if span := zipkin.SpanFromContext(ctx); span != nil {
+				spanIDs = append(spanIDs, string(call.Op)+" "+span.Context().ID.String())
+			}
+			return ctx, nil
+		}},
+	))
+	defer db.Close()
+	defer spans.Close()
+
+	root, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	if _, err := db.ExecContext(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	root.Finish()
+
+	// the tracing runs first so the interceptors see the span of the
+	// operation, the rows being intercepted within the span of the query
+	s := spans.Flush()
+	if want, have := 3, len(s); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	want := []string{
+		"exec " + s[0].ID.String(),
+		"query " + s[1].ID.String(),
+		"rows_next " + s[1].ID.String(),
+		"rows_next " + s[1].ID.String(),
+		"rows_close " + s[1].ID.String(),
+	}
+	if have := spanIDs; strings.Join(want, ",") != strings.Join(have, ",") {
+		t.Errorf("unexpected spans in the interceptors\nwant: %v\nhave: %v", want, have)
+	}
+}
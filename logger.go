@@ -0,0 +1,107 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strconv"
+	"time"
+)
+
+// QueryLog describes a traced call. It carries the same data as the span of
+// the call so logs can be linked to the trace.
+type QueryLog struct {
+	// Method is the name of the span, e.g. sql/query.
+	Method string
+
+	// Query is the query, truncated to MaxQueryLength. It is only set if the
+	// TagQuery option is enabled.
+	Query string
+
+	// Args holds the query parameters keyed by name, or by position for the
+	// positional ones, formatted and redacted like the span tags. It is only
+	// set if the TagQueryParams option is enabled.
+	Args map[string]string
+
+	// Duration is the time the call took.
+	Duration time.Duration
+
+	// RowsAffected is the number of rows affected by an exec, -1 if unknown.
+	// It is only set if the TagAffectedRows option is enabled.
+	RowsAffected int64
+
+	// Err is the error the call failed with, if any.
+	Err error
+
+	// TraceID and SpanID identify the span of the call.
+	TraceID string
+	SpanID  string
+}
+
+// Logger is called after every traced call with ctx being the context of the
+// call.
+type Logger func(ctx context.Context, l QueryLog)
+
+// logCall passes the call to the Logger, if any. res is only considered for
+// the successful execs.
+func logCall(ctx context.Context, span Span, options TraceOptions, method, query string, args []driver.NamedValue, startTime time.Time, res driver.Result, err error) {
+	if options.Logger == nil {
+		return
+	}
+
+	sc := span.SpanContext()
+	l := QueryLog{
+		Method:       method,
+		Duration:     time.Since(startTime),
+		RowsAffected: -1,
+		Err:          err,
+		TraceID:      sc.TraceID,
+		SpanID:       sc.SpanID,
+	}
+	if options.TagQuery && query != "" {
+		if options.MaxQueryLength > 0 {
+			query, _ = truncate(query, options.MaxQueryLength)
+		}
+		l.Query = query
+		if options.TagQueryParams && len(args) > 0 {
+			l.Args = make(map[string]string, len(args))
+			for _, arg := range args {
+				key := arg.Name
+				if key == "" {
+					key = strconv.Itoa(arg.Ordinal)
+				}
+				l.Args[key], _ = argToTagValue(redactArg(arg, options), options)
+			}
+		}
+	}
+	if zr, ok := res.(zResult); ok {
+		// avoid the sql/rows_affected span
+		res = zr.parent
+	}
+	// RowsAffected may be costly or consume the result for some drivers, it
+	// is only called when the span would record it too
+	if options.TagAffectedRows && res != nil && err == nil {
+		if n, rErr := res.RowsAffected(); rErr == nil {
+			l.RowsAffected = n
+		}
+	}
+
+	options.Logger(ctx, l)
+}
+
+// valuesToNamedValues converts the arguments of the non context methods.
+func valuesToNamedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// namedValuesToValues converts the arguments back for the non context methods.
+func namedValuesToValues(named []driver.NamedValue) []driver.Value {
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	return args
+}
@@ -0,0 +1,112 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var logs []QueryLog
+	db, tracer, recorder := createDB(t,
+		WithAllowRootSpan(true),
+		WithTagQuery(true),
+		WithTagQueryParams(true),
+		WithTagAffectedRows(true),
+		WithLogger(func(_ context.Context, l QueryLog) {
+			logs = append(logs, l)
+		}),
+	)
+	defer db.Close()
+	defer recorder.Close()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS logger_test (id INTEGER)"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO logger_test (id) VALUES (?)", 7); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err := db.QueryContext(ctx, "SELECT * FROM missing_table"); err == nil {
+		t.Fatal("expected error")
+	}
+	span.Finish()
+
+	spans := recorder.Flush()
+	if want, have := 3, len(logs); want != have {
+		t.Fatalf("unexpected number of logs, want: %d, have: %d", want, have)
+	}
+
+	insert := logs[1]
+	if want, have := "sql/exec", insert.Method; want != have {
+		t.Errorf("unexpected method, want: %s, have: %s", want, have)
+	}
+	if want, have := "INSERT INTO logger_test (id) VALUES (?)", insert.Query; want != have {
+		t.Errorf("unexpected query, want: %s, have: %s", want, have)
+	}
+	if want, have := "7", insert.Args["1"]; want != have {
+		t.Errorf("unexpected arg, want: %s, have: %s", want, have)
+	}
+	if want, have := int64(1), insert.RowsAffected; want != have {
+		t.Errorf("unexpected rows affected, want: %d, have: %d", want, have)
+	}
+	if want, have := span.Context().TraceID.String(), insert.TraceID; want != have {
+		t.Errorf("unexpected trace id, want: %s, have: %s", want, have)
+	}
+	if want, have := spans[1].ID.String(), insert.SpanID; want != have {
+		t.Errorf("unexpected span id, want: %s, have: %s", want, have)
+	}
+
+	query := logs[2]
+	if query.Err == nil {
+		t.Error("expected error")
+	}
+	if want, have := int64(-1), query.RowsAffected; want != have {
+		t.Errorf("unexpected rows affected, want: %d, have: %d", want, have)
+	}
+}
+
+func TestLoggerArgRedactor(t *testing.T) {
+	var logs []QueryLog
+	db, _, recorder := createDB(t,
+		WithAllowRootSpan(true),
+		WithTagQuery(true),
+		WithTagQueryParams(true),
+		WithArgRedactor(func(arg driver.NamedValue) interface{} {
+			if arg.Name == "password" {
+				return "[redacted]"
+			}
+			return arg.Value
+		}),
+		WithLogger(func(_ context.Context, l QueryLog) {
+			logs = append(logs, l)
+		}),
+	)
+	defer db.Close()
+	defer recorder.Close()
+
+	if _, err := db.ExecContext(context.Background(), "SELECT :login, :password", sql.Named("login", "alice"), sql.Named("password", "secret")); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans := recorder.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := 1, len(logs); want != have {
+		t.Fatalf("unexpected number of logs, want: %d, have: %d", want, have)
+	}
+
+	for key, want := range map[string]string{"login": "alice", "password": "[redacted]"} {
+		if have := logs[0].Args[key]; want != have {
+			t.Errorf("unexpected logged %s, want: %s, have: %s", key, want, have)
+		}
+		if have := spans[0].Tags["sql.arg."+key]; want != have {
+			t.Errorf("unexpected %s tag, want: %s, have: %s", key, want, have)
+		}
+	}
+	if want, have := int64(-1), logs[0].RowsAffected; want != have {
+		t.Errorf("unexpected rows affected, want: %d, have: %d", want, have)
+	}
+}
@@ -0,0 +1,219 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Metrics records measurements of the database calls. Unlike spans, which
+// are subject to sampling and AllowRootSpan, every call is measured.
+type Metrics interface {
+	// ObserveLatency records the duration of a call.
+	ObserveLatency(labels MetricLabels, d time.Duration)
+
+	// IncError counts a failed call.
+	IncError(labels MetricLabels)
+}
+
+// PoolMetrics is implemented by the Metrics which can also expose the
+// connection pool statistics of the databases opened with Open and OpenDB.
+type PoolMetrics interface {
+	// ObservePool exposes the statistics of the connection pool of db, named
+	// name, e.g. by reading db.Stats when the metrics are collected, until
+	// stop is called. Open and OpenDB call stop once db is closed, from Go 1.17
+	// on as older versions don't close the connectors, so pool names must be
+	// unique among the databases in use. An error is returned if name is
+	// already observed.
+	ObservePool(name string, db *sql.DB) (stop func(), err error)
+}
+
+// MetricLabels describes a measured call.
+type MetricLabels struct {
+	// Operation is the name of the span describing the call, e.g. sql/query.
+	Operation string
+
+	// Fingerprint identifies the shape of the query, if any. See the
+	// sql.query.fingerprint tag.
+	Fingerprint string
+
+	// ErrorClass is set for failed calls only. It is one of bad_conn,
+	// deadline_exceeded, canceled, tx_done or conn_done for the well known
+	// errors and the Go type of the error otherwise, e.g. *pq.Error.
+	ErrorClass string
+}
+
+// recordMetrics measures a call started at startTime which ended with the
+// error pointed by err. Calls returning driver.ErrSkip are not measured as
+// database/sql falls back to another method. fp is the fingerprint of the
+// query of the call, if any.
+func recordMetrics(m Metrics, operation, fp string, startTime time.Time, err *error) {
+	if m == nil || *err == driver.ErrSkip {
+		return
+	}
+
+	labels := MetricLabels{Operation: operation, Fingerprint: fp}
+	m.ObserveLatency(labels, time.Since(startTime))
+	if *err != nil {
+		labels.ErrorClass = errorClass(*err)
+		m.IncError(labels)
+	}
+}
+
+// callFingerprint returns the fingerprint of query if it is needed by the span
+// or the metrics of a call, computing it once for both.
+func callFingerprint(query string, traced bool, options TraceOptions) string {
+	if !traced && options.Metrics == nil {
+		return ""
+	}
+	return fingerprint(query)
+}
+
+func errorClass(err error) string {
+	switch {
+	case errorIs(err, driver.ErrBadConn):
+		return "bad_conn"
+	case errorIs(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errorIs(err, context.Canceled):
+		return "canceled"
+	case errorIs(err, sql.ErrTxDone):
+		return "tx_done"
+	case errorIs(err, errConnDone):
+		return "conn_done"
+	default:
+		return fmt.Sprintf("%T", err)
+	}
+}
+
+// latencyBuckets are the upper bounds of the latency histogram buckets of the
+// expvar metrics.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+var (
+	// expvarMu serializes the creation of the expvar variables and guards
+	// expvarPools.
+	expvarMu sync.Mutex
+	// expvarPools holds the observed databases by pool name for every expvar
+	// Metrics root.
+	expvarPools = make(map[*expvar.Map]map[string]*sql.DB)
+)
+
+// Type assertion
+var _ PoolMetrics = expvarMetrics{}
+
+// expvarMetrics implements Metrics on top of expvar.
+type expvarMetrics struct {
+	root *expvar.Map
+}
+
+// NewExpvarMetrics returns Metrics published through expvar under name. For
+// every operation and fingerprint pair, keyed "<operation> <fingerprint>", it
+// exposes the calls count, the total latency in nanoseconds, a latency
+// histogram keyed by bucket upper bound and the error counts by class. The
+// returned Metrics implement PoolMetrics, the sql.DBStats of the observed
+// pools being keyed "pool <name>", which is null once the pool is closed.
+// Calling it again with the same name returns Metrics sharing the same
+// variables.
+func NewExpvarMetrics(name string) Metrics {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if m, ok := expvar.Get(name).(*expvar.Map); ok {
+		return expvarMetrics{root: m}
+	}
+	return expvarMetrics{root: expvar.NewMap(name)}
+}
+
+func (m expvarMetrics) ObserveLatency(labels MetricLabels, d time.Duration) {
+	call := m.call(labels)
+	call.Add("count", 1)
+	call.Add("latency_ns", int64(d))
+
+	bucket := "+Inf"
+	for _, b := range latencyBuckets {
+		if d <= b {
+			bucket = b.String()
+			break
+		}
+	}
+	call.Get("latency").(*expvar.Map).Add(bucket, 1)
+}
+
+func (m expvarMetrics) IncError(labels MetricLabels) {
+	m.call(labels).Get("errors").(*expvar.Map).Add(labels.ErrorClass, 1)
+}
+
+func (m expvarMetrics) ObservePool(name string, db *sql.DB) (func(), error) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	pools := expvarPools[m.root]
+	if pools == nil {
+		pools = make(map[string]*sql.DB)
+		expvarPools[m.root] = pools
+	}
+	if _, ok := pools[name]; ok {
+		return nil, fmt.Errorf("zipkinsql: pool %q is already observed", name)
+	}
+	pools[name] = db
+
+	// the variable is kept once the pool is closed, expvar not allowing to
+	// remove it, and reused if a pool of the same name is observed again
+	key := "pool " + name
+	if m.root.Get(key) == nil {
+		m.root.Set(key, expvar.Func(func() interface{} {
+			expvarMu.Lock()
+			db := expvarPools[m.root][name]
+			expvarMu.Unlock()
+			if db == nil {
+				return nil
+			}
+			return db.Stats()
+		}))
+	}
+
+	return func() {
+		expvarMu.Lock()
+		defer expvarMu.Unlock()
+		if pools[name] == db {
+			delete(pools, name)
+		}
+	}, nil
+}
+
+func (m expvarMetrics) call(labels MetricLabels) *expvar.Map {
+	key := labels.Operation + " " + labels.Fingerprint
+	if call, ok := m.root.Get(key).(*expvar.Map); ok {
+		return call
+	}
+
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	// concurrent callers may have initialized the call in the meantime
+	if call, ok := m.root.Get(key).(*expvar.Map); ok {
+		return call
+	}
+
+	call := new(expvar.Map).Init()
+	call.Set("count", new(expvar.Int))
+	call.Set("latency_ns", new(expvar.Int))
+	call.Set("latency", new(expvar.Map).Init())
+	call.Set("errors", new(expvar.Map).Init())
+	m.root.Set(key, call)
+	return call
+}
@@ -0,0 +1,51 @@
+//go:build go1.17
+// +build go1.17
+
+package zipkinsql
+
+import (
+	"database/sql"
+	"expvar"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestExpvarPoolClosed(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+	metrics := NewExpvarMetrics("zipkinsql_test_pool_closed").(expvarMetrics)
+
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", tracer, WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err = Open("sqlite3", "file:test.db?cache=shared&mode=memory", tracer, WithMetrics(metrics)); err == nil {
+		t.Error("expected error observing the same pool name twice")
+	}
+
+	stats, ok := metrics.root.Get("pool sqlite3").(expvar.Func)
+	if !ok {
+		t.Fatal("expected the pool statistics to be published")
+	}
+	if _, ok = stats().(sql.DBStats); !ok {
+		t.Errorf("unexpected pool statistics: %v", stats())
+	}
+
+	// the pool is no longer observed once the database is closed
+	db.Close()
+	if have := stats(); have != nil {
+		t.Errorf("unexpected statistics of a closed pool: %v", have)
+	}
+
+	other, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", tracer, WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer other.Close()
+	if _, ok = stats().(sql.DBStats); !ok {
+		t.Errorf("unexpected pool statistics: %v", stats())
+	}
+}
@@ -0,0 +1,86 @@
+package zipkinsql
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+)
+
+type metricsRecorder struct {
+	mu        sync.Mutex
+	latencies []MetricLabels
+	errors    []MetricLabels
+}
+
+func (m *metricsRecorder) ObserveLatency(labels MetricLabels, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies = append(m.latencies, labels)
+}
+
+func (m *metricsRecorder) IncError(labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors = append(m.errors, labels)
+}
+
+func TestMetricsWithoutSpans(t *testing.T) {
+	metrics := &metricsRecorder{}
+	db, _, recorder := createDB(t, WithAllowRootSpan(false), WithMetrics(metrics))
+	defer db.Close()
+	defer recorder.Close()
+
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err := db.ExecContext(context.Background(), "SELECT * FROM missing_table"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if want, have := 0, len(recorder.Flush()); want != have {
+		t.Errorf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+
+	if want, have := 2, len(metrics.latencies); want != have {
+		t.Fatalf("unexpected number of latencies, want: %d, have: %d", want, have)
+	}
+	if want, have := (MetricLabels{Operation: "sql/exec", Fingerprint: fingerprint("SELECT 1")}), metrics.latencies[0]; want != have {
+		t.Errorf("unexpected labels, want: %+v, have: %+v", want, have)
+	}
+
+	if want, have := 1, len(metrics.errors); want != have {
+		t.Fatalf("unexpected number of errors, want: %d, have: %d", want, have)
+	}
+	if want, have := fingerprint("SELECT * FROM missing_table"), metrics.errors[0].Fingerprint; want != have {
+		t.Errorf("unexpected fingerprint, want: %s, have: %s", want, have)
+	}
+	if want, have := "sqlite3.Error", metrics.errors[0].ErrorClass; want != have {
+		t.Errorf("unexpected error class, want: %s, have: %s", want, have)
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	m := NewExpvarMetrics("zipkinsql_test")
+	labels := MetricLabels{Operation: "sql/query", Fingerprint: "abc"}
+	m.ObserveLatency(labels, 2*time.Millisecond)
+	m.ObserveLatency(labels, time.Minute)
+	labels.ErrorClass = "bad_conn"
+	NewExpvarMetrics("zipkinsql_test").IncError(labels)
+
+	call := expvar.Get("zipkinsql_test").(*expvar.Map).Get("sql/query abc").(*expvar.Map)
+	if want, have := "2", call.Get("count").String(); want != have {
+		t.Errorf("unexpected count, want: %s, have: %s", want, have)
+	}
+	latency := call.Get("latency").(*expvar.Map)
+	if want, have := "1", latency.Get("5ms").String(); want != have {
+		t.Errorf("unexpected 5ms bucket, want: %s, have: %s", want, have)
+	}
+	if want, have := "1", latency.Get("+Inf").String(); want != have {
+		t.Errorf("unexpected +Inf bucket, want: %s, have: %s", want, have)
+	}
+	if want, have := "1", call.Get("errors").(*expvar.Map).Get("bad_conn").String(); want != have {
+		t.Errorf("unexpected errors, want: %s, have: %s", want, have)
+	}
+}
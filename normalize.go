@@ -0,0 +1,117 @@
+package zipkinsql
+
+import (
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	valueListRe = regexp.MustCompile(`\?(\s*,\s*\?)+`)
+	rowListRe   = regexp.MustCompile(`\(\?\)(\s*,\s*\(\?\))+`)
+)
+
+// normalizeQuery returns the shape of a SQL statement so that statements only
+// differing by their values can be grouped together: comments are removed,
+// whitespace is collapsed, literals and placeholders are replaced by ? and
+// lists of values like IN (1, 2, 3) are reduced to a single ?.
+func normalizeQuery(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	space := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			space = true
+			continue
+		case ch == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			space = true
+			continue
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			space = true
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		switch {
+		case ch == '\'':
+			// string literal, quotes are escaped by doubling them
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteByte('?')
+		case ch == '"' || ch == '`':
+			// quoted identifier, kept as is
+			end := i + 1
+			for end < len(query) && query[end] != ch {
+				end++
+			}
+			if end < len(query) {
+				end++
+			}
+			b.WriteString(query[i:end])
+			i = end - 1
+		case isDigit(ch) && !isIdentByte(prevByte(query, i)):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		case (ch == '$' || ch == ':' || ch == '@') && i+1 < len(query) && isIdentByte(query[i+1]) && !isIdentByte(prevByte(query, i)) && prevByte(query, i) != ':':
+			// numbered or named placeholders: $1, :name, @p1
+			for i+1 < len(query) && isIdentByte(query[i+1]) {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	shape := valueListRe.ReplaceAllString(b.String(), "?")
+	return rowListRe.ReplaceAllString(shape, "(?)")
+}
+
+func prevByte(s string, i int) byte {
+	if i == 0 {
+		return 0
+	}
+	return s[i-1]
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentByte(ch byte) bool {
+	return ch == '_' || isDigit(ch) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// fingerprint returns a stable identifier of the shape of a SQL statement,
+// allowing to aggregate statements only differing by their values.
+func fingerprint(query string) string {
+	h := fnv.New64a()
+	h.Write([]byte(normalizeQuery(query)))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,60 @@
+package zipkinsql
+
+import "testing"
+
+func TestNormalizeQuery(t *testing.T) {
+	testCases := map[string]string{
+		"SELECT 1":                                          "SELECT ?",
+		"SELECT * FROM users WHERE id = 42":                 "SELECT * FROM users WHERE id = ?",
+		"SELECT * FROM users WHERE id = $1":                 "SELECT * FROM users WHERE id = ?",
+		"SELECT * FROM users WHERE name = 'O''Brien'":       "SELECT * FROM users WHERE name = ?",
+		"SELECT * FROM users WHERE name = :name":            "SELECT * FROM users WHERE name = ?",
+		"SELECT * FROM users WHERE id IN (1, 2, 3)":         "SELECT * FROM users WHERE id IN (?)",
+		"SELECT * FROM users WHERE id IN (?,?,?)":           "SELECT * FROM users WHERE id IN (?)",
+		"SELECT \"col1\" FROM t2 WHERE price > 1.5":         "SELECT \"col1\" FROM t2 WHERE price > ?",
+		"SELECT a::int FROM t":                              "SELECT a::int FROM t",
+		"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y')":    "INSERT INTO t (a, b) VALUES (?)",
+		"SELECT  *\n\tFROM t -- comment\nWHERE /* c */ a=1": "SELECT * FROM t WHERE a=?",
+	}
+	for query, want := range testCases {
+		if have := normalizeQuery(query); want != have {
+			t.Errorf("unexpected shape for %q, want: %q, have: %q", query, want, have)
+		}
+	}
+}
+
+func TestFingerprintTag(t *testing.T) {
+	db, _, recorder := createDB(t, WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	for _, query := range []string{"SELECT 1 WHERE 1 = 1", "SELECT 2 WHERE 1 = 3"} {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		rows.Close()
+	}
+
+	stmt, err := db.Prepare("SELECT ? WHERE 1 = ?")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(4, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+
+	spans := recorder.Flush()
+	if want, have := 4, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	want := fingerprint("SELECT ? WHERE ? = ?")
+	for _, span := range spans {
+		if have := span.Tags["sql.query.fingerprint"]; want != have {
+			t.Errorf("unexpected fingerprint for %s, want: %q, have: %q", span.Name, want, have)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package zipkinsql
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// nPlusOneWindow is the time after which the queries issued under a parent
+// span are forgotten if no other query is issued under it.
+const nPlusOneWindow = time.Minute
+
+// NPlusOne describes a query shape repeatedly issued under the same parent
+// span, which usually denotes an N+1 query pattern.
+type NPlusOne struct {
+	// Query is the normalized shape of the repeated query.
+	Query string
+	// Count is the number of times the query was issued so far.
+	Count int
+	// Parent identifies the span the queries were issued under.
+	Parent SpanContext
+}
+
+// NPlusOneHook is called when an N+1 query pattern is detected.
+type NPlusOneHook func(ctx context.Context, n NPlusOne)
+
+// nPlusOneQueries keeps track of the query shapes issued under each parent
+// span. Parent spans are identified by their trace and span ids so this state
+// is shared among all the wrapped drivers.
+var nPlusOneQueries = &nPlusOneDetector{parents: make(map[SpanContext]*nPlusOneParent)}
+
+type nPlusOneParent struct {
+	shapes map[string]int
+	last   time.Time
+}
+
+type nPlusOneDetector struct {
+	mu        sync.Mutex
+	parents   map[SpanContext]*nPlusOneParent
+	lastPrune time.Time
+}
+
+// observe records a query issued with ctx and, when its shape repeats more
+// than the configured threshold under the same parent span, annotates the
+// parent span and calls the NPlusOneHook. Each shape is reported once per
+// parent span.
+func (d *nPlusOneDetector) observe(ctx context.Context, tracer Tracer, query string, options TraceOptions) {
+	if options.NPlusOneThreshold <= 0 {
+		return
+	}
+
+	parent := tracer.SpanFromContext(ctx)
+	if parent == nil {
+		return
+	}
+
+	sc := parent.SpanContext()
+	shape := normalizeQuery(query)
+	count := d.add(sc, shape)
+	if count != options.NPlusOneThreshold+1 {
+		return
+	}
+
+	parent.Annotate(time.Now(), "sql.n_plus_one: "+strconv.Itoa(count)+" x "+shape)
+	parent.Tag("sql.n_plus_one", shape)
+	if options.NPlusOneHook != nil {
+		options.NPlusOneHook(ctx, NPlusOne{Query: shape, Count: count, Parent: sc})
+	}
+}
+
+// add increments and returns the count of the query shape under the parent.
+func (d *nPlusOneDetector) add(key SpanContext, shape string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(d.lastPrune) > nPlusOneWindow {
+		for k, p := range d.parents {
+			if now.Sub(p.last) > nPlusOneWindow {
+				delete(d.parents, k)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	p, ok := d.parents[key]
+	if !ok {
+		p = &nPlusOneParent{shapes: make(map[string]int)}
+		d.parents[key] = p
+	}
+	p.last = now
+	p.shapes[shape]++
+	return p.shapes[shape]
+}
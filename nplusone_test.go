@@ -0,0 +1,50 @@
+package zipkinsql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNPlusOneDetection(t *testing.T) {
+	var detected []NPlusOne
+	db, tracer, recorder := createDB(t, WithNPlusOneDetection(3, func(_ context.Context, n NPlusOne) {
+		detected = append(detected, n)
+	}))
+	defer db.Close()
+	defer recorder.Close()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	for i := 0; i < 6; i++ {
+		rows, err := db.QueryContext(ctx, "SELECT ? WHERE 1 = 1", i)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		rows.Close()
+	}
+	span.Finish()
+
+	if want, have := 1, len(detected); want != have {
+		t.Fatalf("unexpected number of detections, want: %d, have: %d", want, have)
+	}
+	if want, have := "SELECT ? WHERE ? = ?", detected[0].Query; want != have {
+		t.Errorf("unexpected query shape, want: %q, have: %q", want, have)
+	}
+	if want, have := 4, detected[0].Count; want != have {
+		t.Errorf("unexpected count, want: %d, have: %d", want, have)
+	}
+	if want, have := span.Context().ID.String(), detected[0].Parent.SpanID; want != have {
+		t.Errorf("unexpected parent span, want: %s, have: %s", want, have)
+	}
+
+	spans := recorder.Flush()
+	root := spans[len(spans)-1]
+	if want, have := "root", root.Name; want != have {
+		t.Fatalf("unexpected span name, want: %s, have: %s", want, have)
+	}
+	if want, have := "SELECT ? WHERE ? = ?", root.Tags["sql.n_plus_one"]; want != have {
+		t.Errorf("unexpected sql.n_plus_one tag, want: %q, have: %q", want, have)
+	}
+	if want, have := 1, len(root.Annotations); want != have {
+		t.Errorf("unexpected number of annotations, want: %d, have: %d", want, have)
+	}
+}
@@ -0,0 +1,143 @@
+//go:build go1.10
+// +build go1.10
+
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+)
+
+// Open opens a database traced by zipkinsql with the driver registered as
+// driverName, without registering a wrapped driver. The remote endpoint is
+// detected from dsn unless one is set with WithRemoteEndpoint, and the
+// connection pool statistics are exposed, under the endpoint service name or
+// else driverName, when the Metrics implement PoolMetrics.
+func Open(driverName, dsn string, t *zipkin.Tracer, options ...TraceOption) (*sql.DB, error) {
+	if err := checkTracer(t, options); err != nil {
+		return nil, err
+	}
+
+	d, err := lookupDriver(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if e := endpointFromDSN(dsn); e != nil {
+		// applied last, not to be reset by WithOptions, without appending to
+		// the array of the caller
+		options = append(options[:len(options):len(options)], func(o *TraceOptions) {
+			if o.RemoteEndpoint == nil {
+				o.RemoteEndpoint = e
+			}
+		})
+	}
+	wrapped := Wrap(d, t, options...)
+
+	var connector driver.Connector = dsnConnector{dsn: dsn, driver: wrapped}
+	if dc, ok := wrapped.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return openDB(connector, driverName, options)
+}
+
+var (
+	driversMu sync.Mutex
+	// drivers holds the drivers looked up by Open by name.
+	drivers = make(map[string]driver.Driver)
+)
+
+// lookupDriver returns the driver registered as driverName. database/sql only
+// exposes it through a database, so the first time driverName is looked up a
+// database is opened with dsn, which doesn't connect, and closed right away.
+func lookupDriver(driverName, dsn string) (driver.Driver, error) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if d, ok := drivers[driverName]; ok {
+		return d, nil
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	if err = db.Close(); err != nil {
+		return nil, err
+	}
+	drivers[driverName] = d
+	return d, nil
+}
+
+// OpenDB opens a database traced by zipkinsql using the connector. The
+// connection pool statistics are exposed, under the remote endpoint service
+// name or else the driver type, when the Metrics implement PoolMetrics.
+func OpenDB(c driver.Connector, t *zipkin.Tracer, options ...TraceOption) (*sql.DB, error) {
+	if err := checkTracer(t, options); err != nil {
+		return nil, err
+	}
+	return openDB(WrapConnector(c, t, options...), fmt.Sprintf("%T", c.Driver()), options)
+}
+
+func openDB(c driver.Connector, poolName string, options []TraceOption) (*sql.DB, error) {
+	o := TraceOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+	if o.RemoteEndpoint != nil && o.RemoteEndpoint.ServiceName != "" {
+		poolName = o.RemoteEndpoint.ServiceName
+	}
+
+	cc := &closeConnector{Connector: c}
+	db := sql.OpenDB(cc)
+	if m, ok := o.Metrics.(PoolMetrics); ok {
+		stop, err := m.ObservePool(poolName, db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		cc.stop = stop
+	}
+	return db, nil
+}
+
+// closeConnector stops observing the pool statistics of a database once it is
+// closed, database/sql closing the connectors implementing io.Closer from Go
+// 1.17 on.
+type closeConnector struct {
+	driver.Connector
+	stop func()
+}
+
+func (c *closeConnector) Close() error {
+	if c.stop != nil {
+		c.stop()
+	}
+	if closer, ok := c.Connector.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// dsnConnector implements driver.Connector for the drivers not implementing
+// driver.DriverContext, like database/sql does.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
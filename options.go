@@ -1,9 +1,34 @@
 package zipkinsql
 
 import (
+	"database/sql/driver"
+
 	"github.com/openzipkin/zipkin-go/model"
 )
 
+// defaultMaxTagValueLength is the length query parameter tag values are
+// truncated to if no MaxTagValueLength is set.
+const defaultMaxTagValueLength = 256
+
+// defaultParamTagPrefix is the prefix of query parameter tag keys if no
+// ParamTagPrefix is set.
+const defaultParamTagPrefix = "sql.arg"
+
+// BinaryEncoding sets how binary query parameters are recorded in spans.
+type BinaryEncoding int
+
+// Available binary encodings.
+const (
+	// BinaryText records binary parameters as is.
+	BinaryText BinaryEncoding = iota
+	// BinaryHex records binary parameters hex encoded.
+	BinaryHex
+	// BinaryBase64 records binary parameters base64 encoded.
+	BinaryBase64
+	// BinaryLength only records the length of binary parameters.
+	BinaryLength
+)
+
 // TraceOption allows for managing zipkinsql configuration using functional options.
 type TraceOption func(o *TraceOptions)
 
@@ -37,28 +62,142 @@ type TraceOptions struct {
 	// This setting is a noop if the TagQuery option is set to false.
 	TagQueryParams bool
 
+	// ArgRedactor, if set, returns the value to record in place of a query
+	// parameter, in both the span tags and the QueryLog, e.g. to mask
+	// passwords or personal data.
+	ArgRedactor func(arg driver.NamedValue) interface{}
+
+	// ParamTagPrefix sets the prefix of the query parameter tag keys. Parameters
+	// are recorded as <prefix>.<ordinal>, ordinals starting at 1, or
+	// <prefix>.<name> for named parameters. Defaults to sql.arg when not set.
+	ParamTagPrefix string
+
+	// LegacyParamTagKeys, if set to true, will record query parameters with the
+	// keys used by previous versions: sql.arg<index> for statements executed
+	// without context, sql.arg.<ordinal> or the bare parameter name otherwise.
+	// ParamTagPrefix is ignored when set.
+	LegacyParamTagKeys bool
+
+	// MaxQueryLength, if greater than zero, sets the length recorded queries
+	// are truncated to. Truncated spans are tagged with sql.truncated.
+	MaxQueryLength int
+
+	// MaxTagValueLength sets the length recorded query parameters are truncated
+	// to. Defaults to 256 bytes when not set. Truncated spans are tagged with
+	// sql.truncated.
+	MaxTagValueLength int
+
+	// BinaryEncoding sets how binary query parameters are recorded. Default is
+	// to record them as is.
+	BinaryEncoding BinaryEncoding
+
+	// TimeFormat sets the layout used to record time query parameters. Defaults
+	// to time.RFC3339Nano when not set.
+	TimeFormat string
+
 	// TagAffectedRows, if set to true, will enable the recording of the number of
 	// affected rows for the query. Some engines may include this in the response
 	// of the query but some require an extra query to obtain the number of affected
 	// rows.
 	TagAffectedRows bool
 
+	// TagCancelLatency, if set to true, will enable recording of the time the
+	// driver took to return once the context of a call was canceled, tagged
+	// sql.context.done_latency as for the deadlines. It requires watching the
+	// context of every call from a goroutine.
+	TagCancelLatency bool
+
+	// TagCaller, if set to true, will enable recording of the function and
+	// location of the code issuing the SQL call, found by walking the stack
+	// past database/sql, sqlx, GORM, zipkinsql and CallerSkipPackages frames.
+	// As walking the stack on every call is costly it is not enabled by
+	// WithAllTraceOptions.
+	TagCaller bool
+
+	// CallerSkipPackages holds additional packages (subpackages included) to
+	// walk past when looking for the caller, e.g. a repository helpers package.
+	// This setting is a noop if the TagCaller option is set to false.
+	CallerSkipPackages []string
+
+	// CallerMaxDepth caps the number of stack frames inspected when looking for
+	// the caller. Defaults to 32 when not set.
+	// This setting is a noop if the TagCaller option is set to false.
+	CallerMaxDepth int
+
+	// NPlusOneThreshold, if greater than zero, enables the detection of N+1
+	// query patterns: when a query shape is issued more than NPlusOneThreshold
+	// times under the same parent span, the parent span gets annotated and
+	// tagged with sql.n_plus_one.
+	NPlusOneThreshold int
+
+	// NPlusOneHook, if set, is called whenever an N+1 query pattern is detected,
+	// e.g. for logging purposes.
+	// This setting is a noop if the NPlusOneThreshold option is not set.
+	NPlusOneHook NPlusOneHook
+
+	// Explain, if set, enables the capture of query plans for slow queries. See
+	// ExplainOptions.
+	Explain *ExplainOptions
+
+	// Tracer, if set, is the tracing backend spans are emitted to instead of the
+	// *zipkin.Tracer given when wrapping the driver, e.g. an OpenTelemetry one
+	// from the zipkinsqlotel package. It can't be overridden per call.
+	Tracer Tracer
+
+	// Metrics, if set, records the latency and errors of every call, traced or
+	// not, e.g. through NewExpvarMetrics or the zipkinsqlprom package.
+	Metrics Metrics
+
+	// Logger, if set, is called after every traced call with the data of its
+	// span, so logs can carry the same data and link to the trace.
+	Logger Logger
+
+	// Faults, if set, are injected in the calls for chaos testing. See Fault.
+	Faults []Fault
+
+	// LinkPreparedStmtSpans, if set, makes the spans of the non context Exec
+	// and Query methods of a prepared statement root spans linked to the
+	// prepare span rather than its children.
+	LinkPreparedStmtSpans bool
+
+	// Interceptors, if set, hook the driver operations. See Interceptor.
+	Interceptors []Interceptor
+
+	// SpanNameFormatter, if set, returns the name of the spans from their
+	// default name, e.g. sql/query. Set through WithContextOptions, it names
+	// the spans of the calls made using a given context.
+	SpanNameFormatter func(name string) string
+
 	// DefaultTags will be set to each span as default.
 	DefaultTags map[string]string
 
 	// RemoteEndpoint will include the remote endpoint information into the client
 	// span.
 	RemoteEndpoint *model.Endpoint
+
+	// namedQuery is the named query of the call, see WithContextNamedQuery.
+	namedQuery string
+
+	// spanName overrides the exec and query span names, see
+	// WithContextSpanName.
+	spanName string
+
+	// tracingDisabled disables the spans, see WithContextTracingDisabled.
+	tracingDisabled bool
 }
 
-// WithAllTraceOptions enables all available trace options.
+// WithAllTraceOptions enables all available trace options, except for
+// TagCaller.
+//
+// The tracing backend, metrics, logger, interceptors, faults, explain, N+1
+// hook and argument redactor settings previously set are kept.
 func WithAllTraceOptions() TraceOption {
 	return func(o *TraceOptions) {
-		*o = AllTraceOptions
+		*o = withBackends(AllTraceOptions, *o)
 	}
 }
 
-// AllTraceOptions has all tracing options enabled.
+// AllTraceOptions has all tracing options enabled, except for TagCaller.
 var AllTraceOptions = TraceOptions{
 	AllowRootSpan:    true,
 	RowsAffectedSpan: true,
@@ -71,12 +210,46 @@ var AllTraceOptions = TraceOptions{
 
 // WithOptions sets the zipkinsql tracing middleware options through a single
 // TraceOptions object.
+// The tracing backend, metrics, logger, interceptors, faults, explain, N+1
+// hook and argument redactor settings previously set are kept unless set in
+// options.
 func WithOptions(options TraceOptions) TraceOption {
 	return func(o *TraceOptions) {
-		*o = options
+		*o = withBackends(options, *o)
 	}
 }
 
+// withBackends returns options completed with the settings of prev which are
+// not tracing flags, so replacing all the flags doesn't drop the Tracer the
+// driver was wrapped with.
+func withBackends(options, prev TraceOptions) TraceOptions {
+	if options.Tracer == nil {
+		options.Tracer = prev.Tracer
+	}
+	if options.Metrics == nil {
+		options.Metrics = prev.Metrics
+	}
+	if options.Logger == nil {
+		options.Logger = prev.Logger
+	}
+	if options.Interceptors == nil {
+		options.Interceptors = prev.Interceptors
+	}
+	if options.Faults == nil {
+		options.Faults = prev.Faults
+	}
+	if options.Explain == nil {
+		options.Explain = prev.Explain
+	}
+	if options.NPlusOneHook == nil {
+		options.NPlusOneHook = prev.NPlusOneHook
+	}
+	if options.ArgRedactor == nil {
+		options.ArgRedactor = prev.ArgRedactor
+	}
+	return options
+}
+
 // WithAllowRootSpan if set to true, will allow zipkinsql to create root spans in
 // absence of exisiting spans or even context.
 // Default is to not trace zipkinsql calls if no existing parent span is found
@@ -122,6 +295,58 @@ func WithTagQueryParams(b bool) TraceOption {
 	}
 }
 
+// WithArgRedactor sets the function returning the value to record in place of
+// a query parameter.
+func WithArgRedactor(f func(arg driver.NamedValue) interface{}) TraceOption {
+	return func(o *TraceOptions) {
+		o.ArgRedactor = f
+	}
+}
+
+// WithParamTagPrefix sets the prefix of the query parameter tag keys.
+func WithParamTagPrefix(prefix string) TraceOption {
+	return func(o *TraceOptions) {
+		o.ParamTagPrefix = prefix
+	}
+}
+
+// WithLegacyParamTagKeys if set to true, will record query parameters with the
+// keys used by previous versions.
+func WithLegacyParamTagKeys(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.LegacyParamTagKeys = b
+	}
+}
+
+// WithMaxQueryLength sets the length recorded queries are truncated to.
+func WithMaxQueryLength(n int) TraceOption {
+	return func(o *TraceOptions) {
+		o.MaxQueryLength = n
+	}
+}
+
+// WithMaxTagValueLength sets the length recorded query parameters are
+// truncated to.
+func WithMaxTagValueLength(n int) TraceOption {
+	return func(o *TraceOptions) {
+		o.MaxTagValueLength = n
+	}
+}
+
+// WithBinaryEncoding sets how binary query parameters are recorded.
+func WithBinaryEncoding(e BinaryEncoding) TraceOption {
+	return func(o *TraceOptions) {
+		o.BinaryEncoding = e
+	}
+}
+
+// WithTimeFormat sets the layout used to record time query parameters.
+func WithTimeFormat(layout string) TraceOption {
+	return func(o *TraceOptions) {
+		o.TimeFormat = layout
+	}
+}
+
 // WithTagAffectedRows if set to true, will enable recording of the affected rows
 // number in spans.
 func WithTagAffectedRows(b bool) TraceOption {
@@ -130,6 +355,109 @@ func WithTagAffectedRows(b bool) TraceOption {
 	}
 }
 
+// WithTagCancelLatency if set to true will enable recording of the time the
+// driver took to honor a context cancellation.
+func WithTagCancelLatency(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.TagCancelLatency = b
+	}
+}
+
+// WithTagCaller if set to true, will enable recording of the function and
+// location of the code issuing the SQL call in spans.
+func WithTagCaller(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.TagCaller = b
+	}
+}
+
+// WithCallerSkipPackages sets additional packages to walk past when looking for
+// the caller of a SQL call.
+func WithCallerSkipPackages(pkgs ...string) TraceOption {
+	return func(o *TraceOptions) {
+		o.CallerSkipPackages = pkgs
+	}
+}
+
+// WithCallerMaxDepth caps the number of stack frames inspected when looking for
+// the caller of a SQL call.
+func WithCallerMaxDepth(depth int) TraceOption {
+	return func(o *TraceOptions) {
+		o.CallerMaxDepth = depth
+	}
+}
+
+// WithNPlusOneDetection enables the detection of query shapes issued more than
+// threshold times under the same parent span. The hook is optional and called
+// once per query shape and parent span.
+func WithNPlusOneDetection(threshold int, hook NPlusOneHook) TraceOption {
+	return func(o *TraceOptions) {
+		o.NPlusOneThreshold = threshold
+		o.NPlusOneHook = hook
+	}
+}
+
+// WithExplain enables the capture of query plans for slow queries.
+func WithExplain(e *ExplainOptions) TraceOption {
+	return func(o *TraceOptions) {
+		o.Explain = e
+	}
+}
+
+// WithTracer sets the tracing backend spans are emitted to, replacing the
+// *zipkin.Tracer given when wrapping the driver, which can then be nil.
+func WithTracer(t Tracer) TraceOption {
+	return func(o *TraceOptions) {
+		o.Tracer = t
+	}
+}
+
+// WithMetrics records the latency and errors of every call to m.
+func WithMetrics(m Metrics) TraceOption {
+	return func(o *TraceOptions) {
+		o.Metrics = m
+	}
+}
+
+// WithLogger calls l after every traced call.
+func WithLogger(l Logger) TraceOption {
+	return func(o *TraceOptions) {
+		o.Logger = l
+	}
+}
+
+// WithFaults injects faults in the calls for chaos testing.
+func WithFaults(faults ...Fault) TraceOption {
+	return func(o *TraceOptions) {
+		o.Faults = faults
+	}
+}
+
+// WithLinkPreparedStmtSpans sets whether the spans of the non context Exec and
+// Query methods of a prepared statement are root spans linked to the prepare
+// span rather than its children.
+func WithLinkPreparedStmtSpans(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.LinkPreparedStmtSpans = b
+	}
+}
+
+// WithInterceptors hooks the driver operations with interceptors, run in
+// order.
+func WithInterceptors(interceptors ...Interceptor) TraceOption {
+	return func(o *TraceOptions) {
+		o.Interceptors = interceptors
+	}
+}
+
+// WithSpanNameFormatter sets the function naming the spans from their default
+// name.
+func WithSpanNameFormatter(f func(name string) string) TraceOption {
+	return func(o *TraceOptions) {
+		o.SpanNameFormatter = f
+	}
+}
+
 // WithDefaultTags will be set to each span as default.
 func WithDefaultTags(tags map[string]string) TraceOption {
 	return func(o *TraceOptions) {
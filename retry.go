@@ -0,0 +1,112 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// retryWindow is the time after which a failed attempt is no longer
+// considered when detecting database/sql retries.
+const retryWindow = 10 * time.Second
+
+// badConnRetries keeps track of the operations which failed with
+// driver.ErrBadConn. database/sql transparently retries those operations on
+// a different connection using the same context, so this state is shared
+// among all the wrapped drivers and connections.
+var badConnRetries = &retryTracker{attempts: make(map[retryKey]retryAttempt)}
+
+type retryKey struct {
+	ctx   context.Context
+	name  string
+	query string
+}
+
+type retryAttempt struct {
+	failures int
+	last     time.Time
+}
+
+// retryTracker counts consecutive driver.ErrBadConn failures for an operation
+// identified by its context, span name and query.
+type retryTracker struct {
+	mu        sync.Mutex
+	attempts  map[retryKey]retryAttempt
+	lastPrune time.Time
+}
+
+// attempt returns the number of previous attempts of the operation that
+// failed with driver.ErrBadConn. Zero means this is the first attempt.
+func (r *retryTracker) attempt(ctx context.Context, name, query string) int {
+	if !isTrackable(ctx) {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(time.Now())
+
+	a, ok := r.attempts[retryKey{ctx, name, query}]
+	if !ok || time.Since(a.last) > retryWindow {
+		return 0
+	}
+	return a.failures
+}
+
+// done records the outcome of an operation. A driver.ErrBadConn error means
+// database/sql is likely to retry it, any other outcome finishes the sequence.
+func (r *retryTracker) done(ctx context.Context, name, query string, err error) {
+	if !isTrackable(ctx) {
+		return
+	}
+
+	key := retryKey{ctx, name, query}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.prune(now)
+
+	if err != driver.ErrBadConn {
+		delete(r.attempts, key)
+		return
+	}
+
+	a := r.attempts[key]
+	a.failures++
+	a.last = now
+	r.attempts[key] = a
+}
+
+// prune forgets the attempts older than retryWindow, along with their
+// contexts, at most once per retryWindow. It must be called with r.mu held.
+func (r *retryTracker) prune(now time.Time) {
+	if now.Sub(r.lastPrune) <= retryWindow {
+		return
+	}
+	for k, a := range r.attempts {
+		if now.Sub(a.last) > retryWindow {
+			delete(r.attempts, k)
+		}
+	}
+	r.lastPrune = now
+}
+
+// isTrackable reports whether ctx can identify a single logical call. Shared
+// contexts like context.Background() would mix up unrelated calls and non
+// comparable contexts can't be used as map keys.
+func isTrackable(ctx context.Context) bool {
+	if ctx == nil || ctx == context.Background() || ctx == context.TODO() {
+		return false
+	}
+	return reflect.TypeOf(ctx).Comparable()
+}
+
+func setSpanRetryAttempt(span Span, attempt int) {
+	if attempt > 0 {
+		span.Tag("sql.retry.attempt", strconv.Itoa(attempt))
+	}
+}
@@ -0,0 +1,90 @@
+package zipkinsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestExecContextBadConnRetries(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	// the first two exec calls fail with driver.ErrBadConn
+	failures := int32(2)
+	d := &fakeDriver{exec: func(context.Context, string, bool) (driver.Result, error) {
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			return nil, driver.ErrBadConn
+		}
+		return driver.RowsAffected(1), nil
+	}}
+	db := sql.OpenDB(WrapConnector(d, tracer))
+	defer db.Close()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	if _, err := db.ExecContext(ctx, "UPDATE foo SET bar = 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	span.Finish()
+
+	spans := reporter.Flush()
+	if want, have := 4, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+
+	for i, attempt := range []string{"", "1", "2"} {
+		if want, have := "sql/exec", spans[i].Name; want != have {
+			t.Fatalf("unexpected span name, want: %s, have: %s", want, have)
+		}
+		if want, have := attempt, spans[i].Tags["sql.retry.attempt"]; want != have {
+			t.Errorf("unexpected retry attempt for span %d, want: %q, have: %q", i, want, have)
+		}
+		if _, hasErr := spans[i].Tags["error"]; hasErr != (i < 2) {
+			t.Errorf("unexpected error tag for span %d: %v", i, spans[i].Tags["error"])
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE foo SET bar = 1"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans = reporter.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if attempt, ok := spans[0].Tags["sql.retry.attempt"]; ok {
+		t.Errorf("unexpected retry attempt after success: %s", attempt)
+	}
+}
+
+func TestRetryTrackerPrune(t *testing.T) {
+	r := &retryTracker{attempts: make(map[retryKey]retryAttempt)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.done(ctx, "sql/exec", "SELECT 1", driver.ErrBadConn)
+	if want, have := 1, r.attempt(ctx, "sql/exec", "SELECT 1"); want != have {
+		t.Fatalf("unexpected attempt, want: %d, have: %d", want, have)
+	}
+
+	// the failed attempt expires and is pruned by the next call, whatever its
+	// outcome
+	key := retryKey{ctx, "sql/exec", "SELECT 1"}
+	a := r.attempts[key]
+	a.last = a.last.Add(-2 * retryWindow)
+	r.attempts[key] = a
+	r.lastPrune = r.lastPrune.Add(-2 * retryWindow)
+
+	other, cancelOther := context.WithCancel(context.Background())
+	defer cancelOther()
+	r.done(other, "sql/exec", "SELECT 2", nil)
+	if want, have := 0, len(r.attempts); want != have {
+		t.Errorf("unexpected number of tracked attempts, want: %d, have: %d", want, have)
+	}
+}
@@ -0,0 +1,176 @@
+package zipkinsql
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+// Tracer abstracts the tracing backend spans are emitted to. Zipkin is used
+// unless another Tracer is provided through the Tracer option, e.g. the
+// OpenTelemetry one in the zipkinsqlotel package.
+type Tracer interface {
+	// StartSpan starts a client span, child of the span carried by ctx if any,
+	// and returns it along with a copy of ctx carrying it.
+	StartSpan(ctx context.Context, name string, options SpanOptions) (Span, context.Context)
+
+	// SpanFromContext returns the span carried by ctx, nil if there is none.
+	SpanFromContext(ctx context.Context) Span
+}
+
+// SpanOptions holds the settings of a span being started.
+type SpanOptions struct {
+	// StartTime of the span, defaults to now when zero.
+	StartTime time.Time
+
+	// RemoteEndpoint is the database endpoint, if known.
+	RemoteEndpoint *Endpoint
+
+	// Parent, if set, is the parent of the span in place of the span carried
+	// by ctx. It is a span started by the same Tracer, possibly finished.
+	Parent Span
+
+	// Links are spans related to the span without being its parent. Backends
+	// not supporting links can record them as tags.
+	Links []SpanContext
+}
+
+// Endpoint describes the database a span is sent to.
+type Endpoint struct {
+	ServiceName string
+	IPv4        net.IP
+	IPv6        net.IP
+	Port        uint16
+}
+
+// remoteEndpoint converts the RemoteEndpoint option to the Endpoint given to
+// the Tracer.
+func remoteEndpoint(e *zipkinmodel.Endpoint) *Endpoint {
+	if e == nil {
+		return nil
+	}
+	return &Endpoint{ServiceName: e.ServiceName, IPv4: e.IPv4, IPv6: e.IPv6, Port: e.Port}
+}
+
+// Span is a span of the tracing backend. Spans which are never finished, e.g.
+// the ones of the calls database/sql retries differently after a
+// driver.ErrSkip, must not be reported.
+type Span interface {
+	// SpanContext returns the identifiers of the span.
+	SpanContext() SpanContext
+
+	// Tag sets a tag to the span.
+	Tag(key, value string)
+
+	// Annotate records an event happening at t.
+	Annotate(t time.Time, value string)
+
+	// SetError records err as the error the span ended with.
+	SetError(err error)
+
+	// Finish ends the span now.
+	Finish()
+
+	// FinishedWithDuration ends the span after d since its start time.
+	FinishedWithDuration(d time.Duration)
+}
+
+// SpanContext identifies a span regardless of the tracing backend, ids being
+// hex encoded.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+// zipkinTracer implements Tracer on top of zipkin-go.
+type zipkinTracer struct {
+	tracer *zipkin.Tracer
+}
+
+// errNoTracer is returned when a driver is wrapped without a tracing backend.
+var errNoTracer = errors.New("zipkinsql: either a *zipkin.Tracer or the Tracer option is required")
+
+// checkTracer returns errNoTracer if neither t nor the Tracer option is set.
+func checkTracer(t *zipkin.Tracer, options []TraceOption) error {
+	if t != nil {
+		return nil
+	}
+	o := TraceOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+	if o.Tracer == nil {
+		return errNoTracer
+	}
+	return nil
+}
+
+// newTracer returns the Tracer spans are emitted to, panicking with
+// errNoTracer if there is none rather than on the first traced call.
+func newTracer(t *zipkin.Tracer, o TraceOptions) Tracer {
+	if o.Tracer != nil {
+		return o.Tracer
+	}
+	if t == nil {
+		panic(errNoTracer)
+	}
+	return zipkinTracer{tracer: t}
+}
+
+func (t zipkinTracer) StartSpan(ctx context.Context, name string, options SpanOptions) (Span, context.Context) {
+	opts := []zipkin.SpanOption{zipkin.Kind(zipkinmodel.Client)}
+	if e := options.RemoteEndpoint; e != nil {
+		opts = append(opts, zipkin.RemoteEndpoint(&zipkinmodel.Endpoint{
+			ServiceName: e.ServiceName,
+			IPv4:        e.IPv4,
+			IPv6:        e.IPv6,
+			Port:        e.Port,
+		}))
+	}
+	if !options.StartTime.IsZero() {
+		opts = append(opts, zipkin.StartTime(options.StartTime))
+	}
+
+	var span zipkin.Span
+	if parent, ok := options.Parent.(zipkinSpan); ok {
+		span = t.tracer.StartSpan(name, append(opts, zipkin.Parent(parent.Context()))...)
+		ctx = zipkin.NewContext(ctx, span)
+	} else {
+		span, ctx = t.tracer.StartSpanFromContext(ctx, name, opts...)
+	}
+	// zipkin has no span links, they are recorded as link, link.1, ... tags
+	for i, link := range options.Links {
+		key := "link"
+		if i > 0 {
+			key += "." + strconv.Itoa(i)
+		}
+		span.Tag(key, link.TraceID+":"+link.SpanID)
+	}
+	return zipkinSpan{span}, ctx
+}
+
+func (t zipkinTracer) SpanFromContext(ctx context.Context) Span {
+	if span := zipkin.SpanFromContext(ctx); span != nil {
+		return zipkinSpan{span}
+	}
+	return nil
+}
+
+// zipkinSpan implements Span on top of zipkin-go.
+type zipkinSpan struct {
+	zipkin.Span
+}
+
+func (s zipkinSpan) SpanContext() SpanContext {
+	sc := s.Context()
+	return SpanContext{TraceID: sc.TraceID.String(), SpanID: sc.ID.String()}
+}
+
+func (s zipkinSpan) SetError(err error) {
+	zipkin.TagError.Set(s.Span, err.Error())
+}
@@ -0,0 +1,20 @@
+module github.com/openzipkin-contrib/zipkin-go-sql/zipkinsqlgorm
+
+go 1.20
+
+// Builds within the repository use the root module of the working tree, the
+// replace directive being ignored by the modules requiring this one.
+replace github.com/openzipkin-contrib/zipkin-go-sql => ../
+
+require (
+	github.com/openzipkin-contrib/zipkin-go-sql v0.2.0
+	github.com/openzipkin/zipkin-go v0.2.2
+	gorm.io/driver/sqlite v1.5.5
+	gorm.io/gorm v1.25.7
+)
+
+require (
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/mattn/go-sqlite3 v2.0.3+incompatible // indirect
+)
@@ -0,0 +1,105 @@
+// Package zipkinsqlgorm enriches the spans of zipkinsql wrapped drivers used by
+// GORM with the GORM operation and the model and table of the statement.
+//
+//	driverName, err := zipkinsql.Register("postgres", tracer, zipkinsql.WithAllTraceOptions())
+//	if err != nil {
+//		return err
+//	}
+//	db, err := gorm.Open(postgres.New(postgres.Config{DriverName: driverName, DSN: dsn}))
+//	if err != nil {
+//		return err
+//	}
+//	err = db.Use(zipkinsqlgorm.New())
+//
+// The plugin doesn't start spans of its own, which would double the driver
+// ones. Instead, the exec and query spans of the driver are named after the
+// operation, e.g. "gorm.Create users", and tagged with gorm.model and
+// gorm.table. The queries preloading associations are named after their own
+// statement, e.g. "gorm.Query books", and the begin and commit spans of the
+// default transactions keep their names.
+package zipkinsqlgorm
+
+import (
+	"context"
+
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	"gorm.io/gorm"
+)
+
+// ctxKey is the key of the instance setting holding the statement context as
+// it was before the plugin enriched it.
+const ctxKey = "zipkinsqlgorm:ctx"
+
+// Type assertion
+var _ gorm.Plugin = plugin{}
+
+type plugin struct{}
+
+// New returns the GORM plugin.
+func New() gorm.Plugin {
+	return plugin{}
+}
+
+func (plugin) Name() string {
+	return "zipkinsqlgorm"
+}
+
+func (plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("zipkinsqlgorm:before_create", before("Create")),
+		cb.Create().After("gorm:create").Register("zipkinsqlgorm:after_create", after),
+		cb.Query().Before("gorm:query").Register("zipkinsqlgorm:before_query", before("Query")),
+		cb.Query().After("gorm:query").Register("zipkinsqlgorm:after_query", after),
+		cb.Update().Before("gorm:update").Register("zipkinsqlgorm:before_update", before("Update")),
+		cb.Update().After("gorm:update").Register("zipkinsqlgorm:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("zipkinsqlgorm:before_delete", before("Delete")),
+		cb.Delete().After("gorm:delete").Register("zipkinsqlgorm:after_delete", after),
+		cb.Row().Before("gorm:row").Register("zipkinsqlgorm:before_row", before("Row")),
+		cb.Row().After("gorm:row").Register("zipkinsqlgorm:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("zipkinsqlgorm:before_raw", before("Raw")),
+		cb.Raw().After("gorm:raw").Register("zipkinsqlgorm:after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before returns the callback naming the spans of the statement after the
+// operation and tagging them with its model and table.
+func before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		ctx := stmt.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		db.InstanceSet(ctxKey, ctx)
+
+		name := "gorm." + operation
+		tags := make(map[string]string)
+		if stmt.Table != "" {
+			name += " " + stmt.Table
+			tags["gorm.table"] = stmt.Table
+		}
+		if stmt.Schema != nil {
+			tags["gorm.model"] = stmt.Schema.Name
+		}
+
+		ctx = zipkinsql.WithContextSpanName(ctx, name)
+		if len(tags) > 0 {
+			ctx = zipkinsql.WithContextTags(ctx, tags)
+		}
+		stmt.Context = ctx
+	}
+}
+
+// after restores the statement context so the enrichment doesn't leak to
+// the next operations of the session.
+func after(db *gorm.DB) {
+	if ctx, ok := db.InstanceGet(ctxKey); ok {
+		db.Statement.Context = ctx.(context.Context)
+	}
+}
@@ -0,0 +1,203 @@
+package zipkinsqlgorm
+
+import (
+	"context"
+	"testing"
+
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID   uint
+	Name string
+}
+
+func TestPlugin(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	driverName, err := zipkinsql.Register("sqlite3", tracer, zipkinsql.WithTagQuery(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	db, err := gorm.Open(sqlite.Dialector{
+		DriverName: driverName,
+		DSN:        "file:zipkinsqlgorm.db?cache=shared&mode=memory",
+	}, &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = db.Use(New()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = db.AutoMigrate(&User{}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	if err = db.WithContext(ctx).Create(&User{Name: "alice"}).Error; err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	var users []User
+	if err = db.WithContext(ctx).Find(&users).Error; err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	span.Finish()
+
+	spans := reporter.Flush()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+
+	for i, name := range []string{"gorm.Create users", "gorm.Query users"} {
+		if want, have := name, spans[i].Name; want != have {
+			t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+		}
+		if want, have := "users", spans[i].Tags["gorm.table"]; want != have {
+			t.Errorf("unexpected gorm.table tag, want: %s, have: %s", want, have)
+		}
+		if want, have := "User", spans[i].Tags["gorm.model"]; want != have {
+			t.Errorf("unexpected gorm.model tag, want: %s, have: %s", want, have)
+		}
+		if want, have := span.Context().ID, *spans[i].ParentID; want != have {
+			t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+		}
+	}
+}
+
+type Author struct {
+	ID    uint
+	Name  string
+	Books []Book
+}
+
+type Book struct {
+	ID       uint
+	AuthorID uint
+	Title    string
+}
+
+func TestPluginTransaction(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	driverName, err := zipkinsql.Register("sqlite3", tracer, zipkinsql.WithTagQuery(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	db, err := gorm.Open(sqlite.Dialector{
+		DriverName: driverName,
+		DSN:        "file:zipkinsqlgorm_tx.db?cache=shared&mode=memory",
+	}, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = db.Use(New()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = db.AutoMigrate(&User{}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	reporter.Flush()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	if err = db.WithContext(ctx).Create(&User{Name: "alice"}).Error; err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	span.Finish()
+
+	// the statement span is enclosed by the spans of the transaction GORM
+	// wraps it in, which keep their names
+	spans := reporter.Flush()
+	if want, have := 4, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	for i, name := range []string{"sql/begin_transaction", "gorm.Create users", "sql/commit"} {
+		if want, have := name, spans[i].Name; want != have {
+			t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+		}
+		if want, have := span.Context().ID, *spans[i].ParentID; want != have {
+			t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+		}
+	}
+	begin, create, commit := spans[0], spans[1], spans[2]
+	if create.Timestamp.Before(begin.Timestamp.Add(begin.Duration)) {
+		t.Error("expected the statement to start once the transaction began")
+	}
+	if commit.Timestamp.Before(create.Timestamp.Add(create.Duration)) {
+		t.Error("expected the transaction to commit once the statement ended")
+	}
+	if want, have := "users", create.Tags["gorm.table"]; want != have {
+		t.Errorf("unexpected gorm.table tag, want: %s, have: %s", want, have)
+	}
+}
+
+func TestPluginPreload(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	driverName, err := zipkinsql.Register("sqlite3", tracer, zipkinsql.WithTagQuery(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	db, err := gorm.Open(sqlite.Dialector{
+		DriverName: driverName,
+		DSN:        "file:zipkinsqlgorm_preload.db?cache=shared&mode=memory",
+	}, &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = db.Use(New()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = db.AutoMigrate(&Author{}, &Book{}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err = db.Create(&Author{Name: "alice", Books: []Book{{Title: "Wonderland"}}}).Error; err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	reporter.Flush()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "root")
+	var authors []Author
+	if err = db.WithContext(ctx).Preload("Books").Find(&authors).Error; err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	span.Finish()
+
+	// the preload query is named after its own statement
+	spans := reporter.Flush()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	for i, c := range []struct{ name, table, model string }{
+		{"gorm.Query authors", "authors", "Author"},
+		{"gorm.Query books", "books", "Book"},
+	} {
+		if want, have := c.name, spans[i].Name; want != have {
+			t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+		}
+		if want, have := c.table, spans[i].Tags["gorm.table"]; want != have {
+			t.Errorf("unexpected gorm.table tag, want: %s, have: %s", want, have)
+		}
+		if want, have := c.model, spans[i].Tags["gorm.model"]; want != have {
+			t.Errorf("unexpected gorm.model tag, want: %s, have: %s", want, have)
+		}
+		if want, have := span.Context().ID, *spans[i].ParentID; want != have {
+			t.Errorf("unexpected parent, want: %s, have: %s", want, have)
+		}
+	}
+	if want, have := 1, len(authors[0].Books); want != have {
+		t.Errorf("unexpected number of preloaded books, want: %d, have: %d", want, have)
+	}
+}
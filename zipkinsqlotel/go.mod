@@ -0,0 +1,23 @@
+module github.com/openzipkin-contrib/zipkin-go-sql/zipkinsqlotel
+
+go 1.20
+
+// Builds within the repository use the root module of the working tree, the
+// replace directive being ignored by the modules requiring this one.
+replace github.com/openzipkin-contrib/zipkin-go-sql => ../
+
+require (
+	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	github.com/openzipkin-contrib/zipkin-go-sql v0.2.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/openzipkin/zipkin-go v0.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+)
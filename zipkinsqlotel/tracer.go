@@ -0,0 +1,140 @@
+// Package zipkinsqlotel allows zipkinsql wrapped drivers to emit their spans
+// to OpenTelemetry instead of Zipkin.
+//
+//	driverName, err := zipkinsql.Register(
+//		"postgres",
+//		nil,
+//		zipkinsql.WithTracer(zipkinsqlotel.NewTracer(otel.GetTracerProvider())),
+//		zipkinsql.WithAllTraceOptions(),
+//	)
+package zipkinsqlotel
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans emitted through this package.
+const instrumentationName = "github.com/openzipkin-contrib/zipkin-go-sql"
+
+// Type assertions
+var (
+	_ zipkinsql.Tracer = tracer{}
+	_ zipkinsql.Span   = &span{}
+)
+
+// NewTracer returns a zipkinsql.Tracer emitting spans through the provided
+// OpenTelemetry TracerProvider.
+func NewTracer(tp trace.TracerProvider) zipkinsql.Tracer {
+	return tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t tracer) StartSpan(ctx context.Context, name string, options zipkinsql.SpanOptions) (zipkinsql.Span, context.Context) {
+	startTime := options.StartTime
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(startTime),
+	}
+	if e := options.RemoteEndpoint; e != nil {
+		attrs := []attribute.KeyValue{attribute.String("peer.service", e.ServiceName)}
+		if e.IPv4 != nil {
+			attrs = append(attrs, attribute.String("net.peer.ip", e.IPv4.String()))
+		} else if e.IPv6 != nil {
+			attrs = append(attrs, attribute.String("net.peer.ip", e.IPv6.String()))
+		}
+		if e.Port != 0 {
+			attrs = append(attrs, attribute.Int("net.peer.port", int(e.Port)))
+		}
+		opts = append(opts, trace.WithAttributes(attrs...))
+	}
+
+	for _, link := range options.Links {
+		if sc, ok := spanContext(link); ok {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+	}
+
+	if parent, ok := options.Parent.(*span); ok {
+		ctx = trace.ContextWithSpan(ctx, parent.span)
+	}
+
+	ctx, s := t.tracer.Start(ctx, name, opts...)
+	return &span{span: s, startTime: startTime}, ctx
+}
+
+func (t tracer) SpanFromContext(ctx context.Context) zipkinsql.Span {
+	s := trace.SpanFromContext(ctx)
+	if !s.SpanContext().IsValid() {
+		return nil
+	}
+	return &span{span: s}
+}
+
+// spanContext converts a zipkinsql.SpanContext, 64 bits trace ids being left
+// padded with zeros.
+func spanContext(sc zipkinsql.SpanContext) (trace.SpanContext, bool) {
+	hexTraceID := sc.TraceID
+	if len(hexTraceID) < 32 {
+		hexTraceID = strings.Repeat("0", 32-len(hexTraceID)) + hexTraceID
+	}
+	traceID, err := trace.TraceIDFromHex(hexTraceID)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanID, err := trace.SpanIDFromHex(sc.SpanID)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}), true
+}
+
+// span implements zipkinsql.Span. startTime is only known for the spans
+// started by zipkinsql.
+type span struct {
+	span      trace.Span
+	startTime time.Time
+}
+
+func (s *span) SpanContext() zipkinsql.SpanContext {
+	sc := s.span.SpanContext()
+	return zipkinsql.SpanContext{TraceID: sc.TraceID().String(), SpanID: sc.SpanID().String()}
+}
+
+func (s *span) Tag(key, value string) {
+	s.span.SetAttributes(attribute.String(key, value))
+}
+
+func (s *span) Annotate(t time.Time, value string) {
+	s.span.AddEvent(value, trace.WithTimestamp(t))
+}
+
+func (s *span) SetError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *span) Finish() {
+	s.span.End()
+}
+
+func (s *span) FinishedWithDuration(d time.Duration) {
+	if s.startTime.IsZero() {
+		s.span.End()
+		return
+	}
+	s.span.End(trace.WithTimestamp(s.startTime.Add(d)))
+}
@@ -0,0 +1,122 @@
+package zipkinsqlotel
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	driverName, err := zipkinsql.Register(
+		"sqlite3",
+		nil,
+		zipkinsql.WithTracer(NewTracer(tp)),
+		zipkinsql.WithTagQuery(true),
+	)
+	if err != nil {
+		t.Fatalf("unable to register driver: %s", err.Error())
+	}
+
+	db, err := sql.Open(driverName, "file:test.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "root")
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	rows.Close()
+
+	if _, err = db.ExecContext(ctx, "SELECT * FROM missing"); err == nil {
+		t.Fatal("expected error")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+
+	query := spans[0]
+	if want, have := "sql/query", query.Name(); want != have {
+		t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+	}
+	if want, have := trace.SpanKindClient, query.SpanKind(); want != have {
+		t.Errorf("unexpected span kind, want: %s, have: %s", want, have)
+	}
+	if want, have := parent.SpanContext().SpanID(), query.Parent().SpanID(); want != have {
+		t.Errorf("unexpected parent span, want: %s, have: %s", want, have)
+	}
+	var tagged bool
+	for _, attr := range query.Attributes() {
+		if attr.Key == "sql.query" && attr.Value.AsString() == "SELECT 1" {
+			tagged = true
+		}
+	}
+	if !tagged {
+		t.Errorf("missing sql.query attribute: %v", query.Attributes())
+	}
+
+	exec := spans[1]
+	if want, have := "sql/exec", exec.Name(); want != have {
+		t.Errorf("unexpected span name, want: %s, have: %s", want, have)
+	}
+	if want, have := "Error", exec.Status().Code.String(); want != have {
+		t.Errorf("unexpected span status, want: %s, have: %s", want, have)
+	}
+}
+
+func TestSpanLinks(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := NewTracer(tp)
+
+	link := zipkinsql.SpanContext{TraceID: "463ac35c9f6413ad", SpanID: "a2fb4a1d1a96d312"}
+	span, _ := tracer.StartSpan(context.Background(), "sql:exec", zipkinsql.SpanOptions{
+		Links: []zipkinsql.SpanContext{link},
+	})
+	span.Finish()
+
+	spans := recorder.Ended()
+	if want, have := 1, len(spans[0].Links()); want != have {
+		t.Fatalf("unexpected number of links, want: %d, have: %d", want, have)
+	}
+	sc := spans[0].Links()[0].SpanContext
+	if want, have := "0000000000000000463ac35c9f6413ad", sc.TraceID().String(); want != have {
+		t.Errorf("unexpected link trace id, want: %s, have: %s", want, have)
+	}
+	if want, have := link.SpanID, sc.SpanID().String(); want != have {
+		t.Errorf("unexpected link span id, want: %s, have: %s", want, have)
+	}
+}
+
+func TestSpanParent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := NewTracer(tp)
+
+	prepare, _ := tracer.StartSpan(context.Background(), "sql/prepare", zipkinsql.SpanOptions{})
+	prepare.Finish()
+	exec, _ := tracer.StartSpan(context.Background(), "sql:exec", zipkinsql.SpanOptions{Parent: prepare})
+	exec.Finish()
+
+	spans := recorder.Ended()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := spans[0].SpanContext().SpanID(), spans[1].Parent().SpanID(); want != have {
+		t.Errorf("unexpected parent span, want: %s, have: %s", want, have)
+	}
+}
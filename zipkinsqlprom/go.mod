@@ -0,0 +1,24 @@
+module github.com/openzipkin-contrib/zipkin-go-sql/zipkinsqlprom
+
+go 1.20
+
+// Builds within the repository use the root module of the working tree, the
+// replace directive being ignored by the modules requiring this one.
+replace github.com/openzipkin-contrib/zipkin-go-sql => ../
+
+require (
+	github.com/openzipkin-contrib/zipkin-go-sql v0.2.0
+	github.com/openzipkin/zipkin-go v0.2.2
+	github.com/prometheus/client_golang v1.19.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	google.golang.org/protobuf v1.32.0 // indirect
+)
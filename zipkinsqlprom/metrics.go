@@ -0,0 +1,82 @@
+// Package zipkinsqlprom exposes the metrics of zipkinsql wrapped drivers to
+// Prometheus.
+//
+//	metrics, err := zipkinsqlprom.NewMetrics(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		return err
+//	}
+//	driverName, err := zipkinsql.Register(
+//		"postgres",
+//		tracer,
+//		zipkinsql.WithMetrics(metrics),
+//	)
+package zipkinsqlprom
+
+import (
+	"database/sql"
+	"time"
+
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Type assertions
+var (
+	_ zipkinsql.Metrics     = metrics{}
+	_ zipkinsql.PoolMetrics = metrics{}
+)
+
+type metrics struct {
+	reg     prometheus.Registerer
+	latency *prometheus.HistogramVec
+	errors  *prometheus.CounterVec
+}
+
+// NewMetrics registers the zipkinsql_latency_seconds histogram, labelled by
+// operation and fingerprint, and the zipkinsql_errors_total counter, also
+// labelled by error_class, to reg and returns the zipkinsql.Metrics feeding
+// them. The connection pool statistics of the databases opened with
+// zipkinsql.Open and zipkinsql.OpenDB are registered as the go_sql_* metrics,
+// labelled by db_name, and unregistered once the databases are closed.
+func NewMetrics(reg prometheus.Registerer) (zipkinsql.Metrics, error) {
+	m := metrics{
+		reg: reg,
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "zipkinsql_latency_seconds",
+			Help:    "Latency of the database calls.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "fingerprint"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "zipkinsql_errors_total",
+			Help: "Number of failed database calls.",
+		}, []string{"operation", "fingerprint", "error_class"}),
+	}
+
+	if err := reg.Register(m.latency); err != nil {
+		return nil, err
+	}
+	if err := reg.Register(m.errors); err != nil {
+		reg.Unregister(m.latency)
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m metrics) ObserveLatency(labels zipkinsql.MetricLabels, d time.Duration) {
+	m.latency.WithLabelValues(labels.Operation, labels.Fingerprint).Observe(d.Seconds())
+}
+
+func (m metrics) IncError(labels zipkinsql.MetricLabels) {
+	m.errors.WithLabelValues(labels.Operation, labels.Fingerprint, labels.ErrorClass).Inc()
+}
+
+func (m metrics) ObservePool(name string, db *sql.DB) (func(), error) {
+	c := collectors.NewDBStatsCollector(db, name)
+	if err := m.reg.Register(c); err != nil {
+		return nil, err
+	}
+	return func() {
+		m.reg.Unregister(c)
+	}, nil
+}
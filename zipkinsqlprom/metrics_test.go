@@ -0,0 +1,110 @@
+package zipkinsqlprom
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	labels := zipkinsql.MetricLabels{Operation: "sql/query", Fingerprint: "abc"}
+	m.ObserveLatency(labels, 20*time.Millisecond)
+	labels.ErrorClass = "bad_conn"
+	m.IncError(labels)
+
+	if want, have := 1, testutil.CollectAndCount(reg, "zipkinsql_latency_seconds"); want != have {
+		t.Errorf("unexpected number of latency series, want: %d, have: %d", want, have)
+	}
+
+	expected := `
+# HELP zipkinsql_errors_total Number of failed database calls.
+# TYPE zipkinsql_errors_total counter
+zipkinsql_errors_total{error_class="bad_conn",fingerprint="abc",operation="sql/query"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "zipkinsql_errors_total"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := NewMetrics(reg); err == nil {
+		t.Error("expected error registering the metrics twice")
+	}
+}
+
+// connector is a driver.Connector which can't connect, the pool statistics
+// being read without connecting.
+type connector struct{}
+
+func (connector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("not connected")
+}
+
+func (connector) Driver() driver.Driver {
+	return nil
+}
+
+func TestPoolMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	tracer, err := zipkin.NewTracer(recorder.NewReporter())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	db, err := zipkinsql.OpenDB(connector{}, tracer,
+		zipkinsql.WithMetrics(m),
+		zipkinsql.WithRemoteEndpoint(model.Endpoint{ServiceName: "users"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer db.Close()
+
+	expected := `
+# HELP go_sql_max_open_connections Maximum number of open connections to the database.
+# TYPE go_sql_max_open_connections gauge
+go_sql_max_open_connections{db_name="users"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "go_sql_max_open_connections"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = zipkinsql.OpenDB(connector{}, tracer,
+		zipkinsql.WithMetrics(m),
+		zipkinsql.WithRemoteEndpoint(model.Endpoint{ServiceName: "users"}),
+	); err == nil {
+		t.Error("expected error observing the same pool name twice")
+	}
+
+	// the collector is unregistered once the database is closed
+	db.Close()
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(""), "go_sql_max_open_connections"); err != nil {
+		t.Error(err)
+	}
+	other, err := zipkinsql.OpenDB(connector{}, tracer,
+		zipkinsql.WithMetrics(m),
+		zipkinsql.WithRemoteEndpoint(model.Endpoint{ServiceName: "users"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	other.Close()
+}
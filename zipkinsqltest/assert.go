@@ -0,0 +1,93 @@
+package zipkinsqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sort"
+	"strings"
+	"testing"
+
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+// Open wraps d with zipkinsql and returns a database using it, along with the
+// tracer and the recorder of its spans, both to be closed by the caller.
+func Open(t testing.TB, d driver.Driver, options ...zipkinsql.TraceOption) (*sql.DB, *zipkin.Tracer, *zipkinreporter.ReporterRecorder) {
+	t.Helper()
+
+	recorder := zipkinreporter.NewReporter()
+	tracer, err := zipkin.NewTracer(recorder)
+	if err != nil {
+		t.Fatalf("unable to create tracer: %s", err.Error())
+	}
+
+	db := sql.OpenDB(zipkinsql.WrapConnector(connector{driver: d}, tracer, options...))
+	return db, tracer, recorder
+}
+
+// connector turns a driver.Driver into a driver.Connector.
+type connector struct {
+	driver driver.Driver
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open("")
+}
+
+func (c connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// AssertSpan fails the test unless spans holds a span named name with, at
+// least, the given tags. It returns the first such span.
+func AssertSpan(t testing.TB, spans []model.SpanModel, name string, tags map[string]string) model.SpanModel {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.Name == name && hasTags(span, tags) {
+			return span
+		}
+	}
+
+	described := make([]string, len(spans))
+	for i, span := range spans {
+		described[i] = describe(span)
+	}
+	t.Errorf("no span named %q with tags %v, have:\n\t%s", name, tags, strings.Join(described, "\n\t"))
+	return model.SpanModel{}
+}
+
+// AssertParent fails the test unless child is a direct child of parent.
+func AssertParent(t testing.TB, child, parent model.SpanModel) {
+	t.Helper()
+
+	if child.ParentID == nil {
+		t.Errorf("span %q has no parent, want: %q", child.Name, parent.Name)
+		return
+	}
+	if child.TraceID != parent.TraceID || *child.ParentID != parent.ID {
+		t.Errorf("span %q has parent %s, want: %q (%s)", child.Name, child.ParentID, parent.Name, parent.ID)
+	}
+}
+
+func hasTags(span model.SpanModel, tags map[string]string) bool {
+	for key, value := range tags {
+		if have, ok := span.Tags[key]; !ok || have != value {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(span model.SpanModel) string {
+	tags := make([]string, 0, len(span.Tags))
+	for key, value := range span.Tags {
+		tags = append(tags, key+"="+value)
+	}
+	sort.Strings(tags)
+	return span.Name + " {" + strings.Join(tags, ", ") + "}"
+}
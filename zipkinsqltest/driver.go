@@ -0,0 +1,300 @@
+// Package zipkinsqltest helps testing code instrumented with zipkinsql without
+// a database. It provides a fake driver answering queries with scripted
+// results, errors and latencies, and assertions on the recorded spans.
+//
+//	d := zipkinsqltest.NewDriver(zipkinsqltest.Script{
+//		Query:   "SELECT name FROM users WHERE id = ?",
+//		Columns: []string{"name"},
+//		Rows:    [][]driver.Value{{"alice"}},
+//	})
+//	db, tracer, recorder := zipkinsqltest.Open(t, d, zipkinsql.WithAllTraceOptions())
+//	defer db.Close()
+//	defer recorder.Close()
+//
+//	span, ctx := tracer.StartSpanFromContext(context.Background(), "FindUser")
+//	name, err := repository.FindUser(ctx, db, 1)
+//	span.Finish()
+//
+//	spans := recorder.Flush()
+//	query := zipkinsqltest.AssertSpan(t, spans, "sql/query", map[string]string{"sql.arg.1": "1"})
+//	zipkinsqltest.AssertParent(t, query, zipkinsqltest.AssertSpan(t, spans, "FindUser", nil))
+package zipkinsqltest
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Pseudo queries matching the transaction operations.
+const (
+	QueryBegin    = "BEGIN"
+	QueryCommit   = "COMMIT"
+	QueryRollback = "ROLLBACK"
+)
+
+// Script describes the outcome of the calls made with a query.
+type Script struct {
+	// Query is the query the script applies to. Transactions are matched by
+	// the QueryBegin, QueryCommit and QueryRollback pseudo queries. An empty
+	// Query matches every call.
+	Query string
+
+	// Args, if set, are the arguments the call must be made with for the
+	// script to apply, as converted by database/sql, e.g. int64 for an int.
+	Args []driver.Value
+
+	// Times, if greater than zero, is the number of calls the script applies
+	// to, the next matching script applying to the following calls. Scripts
+	// apply to every call otherwise.
+	Times int
+
+	// Columns and Rows are the rows returned by the queries.
+	Columns []string
+	Rows    [][]driver.Value
+
+	// RowsAffected and LastInsertID are the result of the execs.
+	RowsAffected int64
+	LastInsertID int64
+
+	// Err, if set, is returned instead of the rows or result.
+	Err error
+
+	// Latency is the time the call takes. The call fails with the context
+	// error if the context is done before.
+	Latency time.Duration
+}
+
+// Driver is a fake driver.Driver answering the calls with the first script
+// matching their query and arguments, in the order the scripts were given.
+// Queries and execs not matching any script fail, the transaction operations
+// succeed.
+type Driver struct {
+	mu      sync.Mutex
+	scripts []Script
+}
+
+// Compile time assertions
+var (
+	_ driver.Driver             = &Driver{}
+	_ driver.Conn               = &conn{}
+	_ driver.ConnBeginTx        = &conn{}
+	_ driver.ConnPrepareContext = &conn{}
+	_ driver.ExecerContext      = &conn{}
+	_ driver.QueryerContext     = &conn{}
+	_ driver.StmtExecContext    = &stmt{}
+	_ driver.StmtQueryContext   = &stmt{}
+)
+
+// NewDriver returns a Driver answering the calls with scripts.
+func NewDriver(scripts ...Script) *Driver {
+	return &Driver{scripts: scripts}
+}
+
+// Expect adds a script, matched after the existing ones.
+func (d *Driver) Expect(s Script) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scripts = append(d.scripts, s)
+}
+
+// Open implements driver.Driver, the name is ignored.
+func (d *Driver) Open(string) (driver.Conn, error) {
+	return &conn{driver: d}, nil
+}
+
+// play returns the script of query and args after waiting for its latency.
+func (d *Driver) play(ctx context.Context, query string, args []driver.NamedValue) (Script, error) {
+	d.mu.Lock()
+	var (
+		s     Script
+		found bool
+	)
+	for i, candidate := range d.scripts {
+		if !candidate.matches(query, args) {
+			continue
+		}
+		s, found = candidate, true
+		if candidate.Times > 0 {
+			if d.scripts[i].Times--; d.scripts[i].Times == 0 {
+				d.scripts = append(d.scripts[:i], d.scripts[i+1:]...)
+			}
+		}
+		break
+	}
+	d.mu.Unlock()
+
+	if !found {
+		switch query {
+		case QueryBegin, QueryCommit, QueryRollback:
+			return Script{}, nil
+		default:
+			return Script{}, fmt.Errorf("zipkinsqltest: unexpected query %q", query)
+		}
+	}
+
+	if s.Latency > 0 {
+		timer := time.NewTimer(s.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return Script{}, ctx.Err()
+		}
+	}
+	return s, s.Err
+}
+
+// matches tells if the script applies to a call made with query and args.
+func (s Script) matches(query string, args []driver.NamedValue) bool {
+	if s.Query != "" && s.Query != query {
+		return false
+	}
+	if s.Args == nil {
+		return true
+	}
+	if len(s.Args) != len(args) {
+		return false
+	}
+	for i, arg := range args {
+		if !reflect.DeepEqual(s.Args[i], arg.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+type conn struct {
+	driver *Driver
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.driver.play(ctx, QueryBegin, nil); err != nil {
+		return nil, err
+	}
+	return &tx{conn: c, ctx: ctx}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	s, err := c.driver.play(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return result{rowsAffected: s.RowsAffected, lastInsertID: s.LastInsertID}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	s, err := c.driver.play(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: s.Columns, values: s.Rows}, nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1 as the number of placeholders is unknown.
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type tx struct {
+	conn *conn
+	ctx  context.Context
+}
+
+func (t *tx) Commit() error {
+	_, err := t.conn.driver.play(t.ctx, QueryCommit, nil)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	_, err := t.conn.driver.play(t.ctx, QueryRollback, nil)
+	return err
+}
+
+type result struct {
+	rowsAffected int64
+	lastInsertID int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
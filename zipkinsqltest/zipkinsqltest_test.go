@@ -0,0 +1,133 @@
+package zipkinsqltest
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+func TestScriptedQuery(t *testing.T) {
+	d := NewDriver(Script{
+		Query:   "SELECT name FROM users WHERE id = ?",
+		Columns: []string{"name"},
+		Rows:    [][]driver.Value{{"alice"}},
+	})
+	db, tracer, recorder := Open(t, d, zipkinsql.WithTagQuery(true), zipkinsql.WithTagQueryParams(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "FindUser")
+	var name string
+	if err := db.QueryRowContext(ctx, "SELECT name FROM users WHERE id = ?", 1).Scan(&name); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	span.Finish()
+
+	if want, have := "alice", name; want != have {
+		t.Errorf("unexpected name, want: %s, have: %s", want, have)
+	}
+
+	spans := recorder.Flush()
+	query := AssertSpan(t, spans, "sql/query", map[string]string{
+		"sql.query": "SELECT name FROM users WHERE id = ?",
+		"sql.arg.1": "1",
+	})
+	AssertParent(t, query, AssertSpan(t, spans, "FindUser", nil))
+}
+
+func TestScriptedErrors(t *testing.T) {
+	errDuplicate := errors.New("duplicate key")
+	d := NewDriver()
+	d.Expect(Script{Query: "INSERT INTO users (name) VALUES (?)", Err: errDuplicate})
+	db, _, recorder := Open(t, d, zipkinsql.WithAllowRootSpan(true))
+	defer db.Close()
+	defer recorder.Close()
+
+	if _, err := db.ExecContext(context.Background(), "INSERT INTO users (name) VALUES (?)", "alice"); err != errDuplicate {
+		t.Errorf("unexpected error, want: %v, have: %v", errDuplicate, err)
+	}
+	if _, err := db.ExecContext(context.Background(), "DELETE FROM users"); err == nil {
+		t.Error("expected error for unscripted query")
+	}
+
+	AssertSpan(t, recorder.Flush(), "sql/exec", map[string]string{"error": errDuplicate.Error()})
+}
+
+func TestScriptedLatency(t *testing.T) {
+	d := NewDriver(Script{Query: "SELECT pg_sleep(1)", Latency: time.Second})
+	db, _, recorder := Open(t, d)
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := db.QueryContext(ctx, "SELECT pg_sleep(1)"); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error, want: %v, have: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestScriptedTimes(t *testing.T) {
+	d := NewDriver(
+		Script{Query: "UPDATE users SET name = ?", Args: []driver.Value{"bob"}, Err: errors.New("locked"), Times: 1},
+		Script{Query: "UPDATE users SET name = ?", Args: []driver.Value{"bob"}, RowsAffected: 2},
+		Script{Query: "UPDATE users SET name = ?", Err: errors.New("unknown user")},
+	)
+	db, _, recorder := Open(t, d)
+	defer db.Close()
+	defer recorder.Close()
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = ?", "bob"); err == nil || err.Error() != "locked" {
+		t.Errorf("unexpected error, want: locked, have: %v", err)
+	}
+	res, err := db.ExecContext(ctx, "UPDATE users SET name = ?", "bob")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("unexpected rows affected, want: 2, have: %d", n)
+	}
+	if _, err = db.ExecContext(ctx, "UPDATE users SET name = ?", "carol"); err == nil || err.Error() != "unknown user" {
+		t.Errorf("unexpected error, want: unknown user, have: %v", err)
+	}
+}
+
+// failureRecorder records the failures of the assertions.
+type failureRecorder struct {
+	testing.TB
+	failures int
+}
+
+func (f *failureRecorder) Helper() {}
+
+func (f *failureRecorder) Errorf(string, ...interface{}) {
+	f.failures++
+}
+
+func TestAssertionFailures(t *testing.T) {
+	parent := model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1}, Name: "parent"}
+	child := model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 2}, Name: "child"}
+	child.Tags = map[string]string{"sql.query": "SELECT 1"}
+
+	f := &failureRecorder{TB: t}
+	AssertSpan(f, []model.SpanModel{child}, "child", map[string]string{"sql.query": "SELECT 2"})
+	AssertSpan(f, []model.SpanModel{child}, "other", nil)
+	AssertParent(f, child, parent)
+	if want, have := 3, f.failures; want != have {
+		t.Errorf("unexpected number of failures, want: %d, have: %d", want, have)
+	}
+
+	child.ParentID = &parent.ID
+	f = &failureRecorder{TB: t}
+	AssertSpan(f, []model.SpanModel{child}, "child", map[string]string{"sql.query": "SELECT 1"})
+	AssertParent(f, child, parent)
+	if want, have := 0, f.failures; want != have {
+		t.Errorf("unexpected number of failures, want: %d, have: %d", want, have)
+	}
+}
@@ -0,0 +1,143 @@
+// Package zipkinsqlx opens jmoiron/sqlx databases traced by zipkinsql.
+//
+//	db, err := zipkinsqlx.Open("postgres", dsn, tracer, zipkinsql.WithAllTraceOptions())
+//	if err != nil {
+//		return err
+//	}
+//	_, err = db.NamedExecContext(ctx, "INSERT INTO users (name) VALUES (:name)", user)
+package zipkinsqlx
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	zipkin "github.com/openzipkin/zipkin-go"
+)
+
+// DB is a *sqlx.DB whose named query methods record the named query, as
+// written before sqlx rebinds it, in the sql.query.named tag of the spans.
+type DB struct {
+	*sqlx.DB
+}
+
+// Open opens a database traced by zipkinsql with zipkinsql.Open, which doesn't
+// register a driver, and binds it the same way sqlx binds driverName. Opening
+// databases repeatedly thus doesn't leak driver registrations.
+func Open(driverName, dataSourceName string, t *zipkin.Tracer, options ...zipkinsql.TraceOption) (*DB, error) {
+	db, err := zipkinsql.Open(driverName, dataSourceName, t, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{DB: sqlx.NewDb(db, driverName)}, nil
+}
+
+// Connect opens a database like Open and verifies it with a ping.
+func Connect(driverName, dataSourceName string, t *zipkin.Tracer, options ...zipkinsql.TraceOption) (*DB, error) {
+	db, err := Open(driverName, dataSourceName, t, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NamedExec executes a named query.
+func (db *DB) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return db.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedExecContext executes a named query.
+func (db *DB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	return db.DB.NamedExecContext(zipkinsql.WithContextNamedQuery(ctx, query), query, arg)
+}
+
+// NamedQuery runs a named query.
+func (db *DB) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
+	return db.NamedQueryContext(context.Background(), query, arg)
+}
+
+// NamedQueryContext runs a named query.
+func (db *DB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	return db.DB.NamedQueryContext(zipkinsql.WithContextNamedQuery(ctx, query), query, arg)
+}
+
+// PrepareNamed prepares a named statement.
+func (db *DB) PrepareNamed(query string) (*sqlx.NamedStmt, error) {
+	return db.PrepareNamedContext(context.Background(), query)
+}
+
+// PrepareNamedContext prepares a named statement. Only the prepare span is
+// tagged with the named query.
+func (db *DB) PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error) {
+	return db.DB.PrepareNamedContext(zipkinsql.WithContextNamedQuery(ctx, query), query)
+}
+
+// Beginx begins a transaction.
+func (db *DB) Beginx() (*Tx, error) {
+	return db.BeginTxx(context.Background(), nil)
+}
+
+// BeginTxx begins a transaction.
+func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx}, nil
+}
+
+// MustBegin begins a transaction and panics on error.
+func (db *DB) MustBegin() *Tx {
+	return db.MustBeginTx(context.Background(), nil)
+}
+
+// MustBeginTx begins a transaction and panics on error.
+func (db *DB) MustBeginTx(ctx context.Context, opts *sql.TxOptions) *Tx {
+	tx, err := db.BeginTxx(ctx, opts)
+	if err != nil {
+		panic(err)
+	}
+	return tx
+}
+
+// Tx is a *sqlx.Tx whose named query methods record the named query in the
+// sql.query.named tag of the spans.
+type Tx struct {
+	*sqlx.Tx
+}
+
+// NamedExec executes a named query within the transaction.
+func (tx *Tx) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return tx.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedExecContext executes a named query within the transaction.
+func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	return tx.Tx.NamedExecContext(zipkinsql.WithContextNamedQuery(ctx, query), query, arg)
+}
+
+// NamedQuery runs a named query within the transaction.
+func (tx *Tx) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
+	return tx.NamedQueryContext(context.Background(), query, arg)
+}
+
+// NamedQueryContext runs a named query within the transaction.
+func (tx *Tx) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	return sqlx.NamedQueryContext(zipkinsql.WithContextNamedQuery(ctx, query), tx.Tx, query, arg)
+}
+
+// PrepareNamed prepares a named statement within the transaction.
+func (tx *Tx) PrepareNamed(query string) (*sqlx.NamedStmt, error) {
+	return tx.PrepareNamedContext(context.Background(), query)
+}
+
+// PrepareNamedContext prepares a named statement within the transaction. Only
+// the prepare span is tagged with the named query.
+func (tx *Tx) PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error) {
+	return tx.Tx.PrepareNamedContext(zipkinsql.WithContextNamedQuery(ctx, query), query)
+}
@@ -0,0 +1,68 @@
+package zipkinsqlx
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	zipkinsql "github.com/openzipkin-contrib/zipkin-go-sql"
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestNamedExec(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	db, err := Connect("sqlite3", "file:zipkinsqlx.db?cache=shared&mode=memory", tracer,
+		zipkinsql.WithAllowRootSpan(true),
+		zipkinsql.WithTagQuery(true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer db.Close()
+
+	if want, have := sqlx.QUESTION, sqlx.BindType(db.DriverName()); want != have {
+		t.Fatalf("unexpected bind type, want: %d, have: %d", want, have)
+	}
+
+	db.MustExec("CREATE TABLE users (name TEXT)")
+	reporter.Flush()
+
+	named := "INSERT INTO users (name) VALUES (:name)"
+	if _, err = db.NamedExec(named, map[string]interface{}{"name": "alice"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	spans := reporter.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want: %d, have: %d", want, have)
+	}
+	if want, have := "INSERT INTO users (name) VALUES (?)", spans[0].Tags["sql.query"]; want != have {
+		t.Errorf("unexpected sql.query tag, want: %q, have: %q", want, have)
+	}
+	if want, have := named, spans[0].Tags["sql.query.named"]; want != have {
+		t.Errorf("unexpected sql.query.named tag, want: %q, have: %q", want, have)
+	}
+}
+
+func TestOpenNoRegistration(t *testing.T) {
+	reporter := zipkinreporter.NewReporter()
+	defer reporter.Close()
+	tracer, _ := zipkin.NewTracer(reporter)
+
+	drivers := len(sql.Drivers())
+	for i := 0; i < 2; i++ {
+		db, err := Open("sqlite3", "file:zipkinsqlx.db?cache=shared&mode=memory", tracer)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		db.Close()
+	}
+	if want, have := drivers, len(sql.Drivers()); want != have {
+		t.Errorf("unexpected number of registered drivers, want: %d, have: %d", want, have)
+	}
+}